@@ -4,20 +4,29 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/payment-processor-rinha/internal/api"
 	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
 	worker "github.com/payment-processor-rinha/internal/application/payment/workers"
+	"github.com/payment-processor-rinha/internal/json"
+	"github.com/payment-processor-rinha/internal/money"
 	"github.com/redis/go-redis/v9"
 )
 
 const redisAddr = "redis:6379"
 
+// shutdownTimeout is how long open connections get to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	redisClient := redis.NewClient(&redis.Options{
@@ -43,10 +52,11 @@ func main() {
 	})
 	defer redisClient.Close()
 
-	concurrency, err := strconv.Atoi(getEnv("CONCURRENCY", "10"))
+	concurrency, err := resolveConcurrency(getEnv("CONCURRENCY", "10"), getEnv("CONCURRENCY_MULTIPLIER", "4"))
 	if err != nil {
 		panic(err)
 	}
+	fmt.Printf("worker concurrency: %d\n", concurrency)
 
 	master, err := strconv.ParseBool(getEnv("MASTER", "false"))
 	if err != nil {
@@ -59,17 +69,512 @@ func main() {
 		panic(err)
 	}
 
+	summaryCacheEnabled, err := strconv.ParseBool(getEnv("SUMMARY_CACHE_ENABLED", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	summaryCacheTTL, err := time.ParseDuration(getEnv("SUMMARY_CACHE_TTL", "1s"))
+	if err != nil {
+		panic(err)
+	}
+
+	// windows are cached by from/to rounded down to this, so queries a few
+	// millis apart share an entry when no payment falls between them
+	summaryCacheGranularity, err := time.ParseDuration(getEnv("SUMMARY_CACHE_GRANULARITY", "1s"))
+	if err != nil {
+		panic(err)
+	}
+
+	retryBudget, err := strconv.Atoi(getEnv("RETRY_BUDGET", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	summaryPrecision, err := strconv.Atoi(getEnv("SUMMARY_PRECISION", "2"))
+	if err != nil {
+		panic(err)
+	}
+
+	queueLIFOThreshold, err := strconv.Atoi(getEnv("QUEUE_LIFO_THRESHOLD", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	queueMaxBytes, err := strconv.ParseInt(getEnv("QUEUE_MAX_BYTES", "0"), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	// QUEUE_PARK_ON_SHUTDOWN saves the in-memory queue, and the tasks workers
+	// were holding, to Redis on shutdown and restores it on startup, instead of
+	// losing it
+	parkQueue, err := strconv.ParseBool(getEnv("QUEUE_PARK_ON_SHUTDOWN", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	routingPolicy := getEnv("ROUTING_POLICY", paymentProcessor.RoutingImmediate)
+	rampWindow, err := time.ParseDuration(getEnv("RECOVERY_RAMP_WINDOW", "10s"))
+	if err != nil {
+		panic(err)
+	}
+
+	compactionRetention, err := time.ParseDuration(getEnv("COMPACTION_RETENTION", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	compactionInterval, err := time.ParseDuration(getEnv("COMPACTION_INTERVAL", "1m"))
+	if err != nil {
+		panic(err)
+	}
+
+	defaultMaxConcurrency, err := strconv.Atoi(getEnv("DEFAULT_MAX_CONCURRENCY", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	fallbackMaxConcurrency, err := strconv.Atoi(getEnv("FALLBACK_MAX_CONCURRENCY", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	strictPayloads, err := strconv.ParseBool(getEnv("STRICT_PAYLOADS", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	allowProcessorOverride, err := strconv.ParseBool(getEnv("ALLOW_PROCESSOR_OVERRIDE", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	queueMaxAge, err := time.ParseDuration(getEnv("QUEUE_MAX_AGE", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	saveBatchInterval, err := time.ParseDuration(getEnv("SAVE_BATCH_INTERVAL", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	saveBatchSize, err := strconv.Atoi(getEnv("SAVE_BATCH_SIZE", "100"))
+	if err != nil {
+		panic(err)
+	}
+
+	validateCorrelationID, err := strconv.ParseBool(getEnv("VALIDATE_CORRELATION_ID", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	overflowToRedis, err := strconv.ParseBool(getEnv("OVERFLOW_TO_REDIS", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	paymentStore := getEnv("PAYMENT_STORE", "redis")
+	paymentStoreFile := getEnv("PAYMENT_STORE_FILE", "/tmp/payments.ndjson")
+	if paymentStore == "file" && compactionRetention > 0 {
+		panic("COMPACTION_RETENTION is not supported with PAYMENT_STORE=file")
+	}
+	saveMode := getEnv("SAVE_MODE", paymentProcessor.SaveModeLua)
+	recordCodec := getEnv("RECORD_CODEC", paymentProcessor.RecordCodecJSON)
+
+	defaultRateLimit, err := strconv.Atoi(getEnv("DEFAULT_RATE_LIMIT", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	fallbackRateLimit, err := strconv.Atoi(getEnv("FALLBACK_RATE_LIMIT", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	queueBackend := getEnv("QUEUE_BACKEND", "memory")
+	queueBatchSize, err := strconv.Atoi(getEnv("QUEUE_BATCH_SIZE", "1"))
+	if err != nil {
+		panic(err)
+	}
+
+	disableFallback, err := strconv.ParseBool(getEnv("DISABLE_FALLBACK", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	strictDefaultMaxRetries, err := strconv.Atoi(getEnv("STRICT_DEFAULT_MAX_RETRIES", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	redisHealthInterval, err := time.ParseDuration(getEnv("REDIS_HEALTH_INTERVAL", "1s"))
+	if err != nil {
+		panic(err)
+	}
+
+	processorIndexes, err := strconv.ParseBool(getEnv("PROCESSOR_INDEXES", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	totalsFlushInterval, err := time.ParseDuration(getEnv("TOTALS_FLUSH_INTERVAL", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	healthRecoveryProbes, err := strconv.Atoi(getEnv("HEALTH_RECOVERY_PROBES", "1"))
+	if err != nil {
+		panic(err)
+	}
+
+	unreachableGrace, err := time.ParseDuration(getEnv("HEALTH_UNREACHABLE_GRACE", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	coldStartUp, err := strconv.ParseBool(getEnv("COLD_START_UP", "true"))
+	if err != nil {
+		panic(err)
+	}
+
+	maxConcurrentSummaries, err := strconv.Atoi(getEnv("MAX_CONCURRENT_SUMMARIES", "2"))
+	if err != nil {
+		panic(err)
+	}
+
+	summaryQueueWait, err := time.ParseDuration(getEnv("SUMMARY_QUEUE_WAIT", "500ms"))
+	if err != nil {
+		panic(err)
+	}
+
+	defaultSuccessCodes, err := parseStatusCodes(os.Getenv("PROCESSOR_DEFAULT_SUCCESS_CODES"))
+	if err != nil {
+		panic(err)
+	}
+
+	fallbackSuccessCodes, err := parseStatusCodes(os.Getenv("PROCESSOR_FALLBACK_SUCCESS_CODES"))
+	if err != nil {
+		panic(err)
+	}
+
+	attemptTimeout, err := time.ParseDuration(getEnv("PROCESSOR_ATTEMPT_TIMEOUT", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	attemptTimeoutGrowth, err := strconv.ParseFloat(getEnv("PROCESSOR_ATTEMPT_TIMEOUT_GROWTH", "2"), 64)
+	if err != nil {
+		panic(err)
+	}
+	if attemptTimeoutGrowth < 1 {
+		panic(fmt.Sprintf("PROCESSOR_ATTEMPT_TIMEOUT_GROWTH must be at least 1, got %g", attemptTimeoutGrowth))
+	}
+
+	// RESULT_LOG_SAMPLE logs one in that many payment tries, 0 disables it
+	resultLogSample, err := strconv.Atoi(getEnv("RESULT_LOG_SAMPLE", "1000"))
+	if err != nil {
+		panic(err)
+	}
+
+	saveRetries, err := strconv.Atoi(getEnv("SAVE_RETRIES", "3"))
+	if err != nil {
+		panic(err)
+	}
+
+	saveRetryBackoff, err := time.ParseDuration(getEnv("SAVE_RETRY_BACKOFF", "20ms"))
+	if err != nil {
+		panic(err)
+	}
+
+	healthProbeOffset, err := time.ParseDuration(getEnv("HEALTH_PROBE_OFFSET", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	processorConnRetries, err := strconv.Atoi(getEnv("PROCESSOR_CONN_RETRIES", "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	paymentTiming, err := strconv.ParseBool(getEnv("PAYMENT_TIMING", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	instanceTagging, err := strconv.ParseBool(getEnv("INSTANCE_TAGGING", "false"))
+	if err != nil {
+		panic(err)
+	}
+	instanceID := os.Getenv("INSTANCE_ID")
+	if instanceID == "" {
+		if instanceID, err = os.Hostname(); err != nil {
+			panic(err)
+		}
+	}
+
+	redisClock, err := strconv.ParseBool(getEnv("REDIS_CLOCK", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	redisClockInterval, err := time.ParseDuration(getEnv("REDIS_CLOCK_INTERVAL", "30s"))
+	if err != nil {
+		panic(err)
+	}
+
+	paymentEvents, err := strconv.ParseBool(getEnv("PAYMENT_EVENTS", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	dryRun, err := strconv.ParseBool(getEnv("DRY_RUN", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	maxAmount, err := strconv.ParseFloat(getEnv("MAX_AMOUNT", "1000000"), 64)
+	if err != nil {
+		panic(err)
+	}
+
+	auditAmount, err := strconv.ParseFloat(getEnv("AUDIT_AMOUNT", "100000"), 64)
+	if err != nil {
+		panic(err)
+	}
+
+	inboundRateLimit, err := strconv.ParseFloat(getEnv("INBOUND_RATE_LIMIT", "0"), 64)
+	if err != nil {
+		panic(err)
+	}
+
+	inboundBurst, err := strconv.Atoi(getEnv("INBOUND_BURST", "100"))
+	if err != nil {
+		panic(err)
+	}
+
+	swapReversedSummaryRange, err := strconv.ParseBool(getEnv("SWAP_REVERSED_SUMMARY_RANGE", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	readHeaderTimeout, err := time.ParseDuration(getEnv("READ_HEADER_TIMEOUT", "2s"))
+	if err != nil {
+		panic(err)
+	}
+
+	readTimeout, err := time.ParseDuration(getEnv("READ_TIMEOUT", "5s"))
+	if err != nil {
+		panic(err)
+	}
+
+	acceptedStatus, err := strconv.Atoi(getEnv("ACCEPTED_STATUS", "202"))
+	if err != nil {
+		panic(err)
+	}
+	switch acceptedStatus {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+	default:
+		panic(fmt.Sprintf("unsupported ACCEPTED_STATUS %d", acceptedStatus))
+	}
+
+	syncPayments, err := strconv.ParseBool(getEnv("SYNC_PAYMENTS", "false"))
+	if err != nil {
+		panic(err)
+	}
+
+	// SUMMARY_AMOUNT_FORMAT=string writes amounts as "12.50", number as 12.5
+	fixedAmountDecimals := 0
+	switch amountFormat := getEnv("SUMMARY_AMOUNT_FORMAT", "number"); amountFormat {
+	case "number":
+	case "string":
+		fixedAmountDecimals = summaryPrecision
+	default:
+		panic(fmt.Sprintf("unsupported SUMMARY_AMOUNT_FORMAT %q", amountFormat))
+	}
+
+	processorClientCert := os.Getenv("PROCESSOR_CLIENT_CERT")
+	processorClientKey := os.Getenv("PROCESSOR_CLIENT_KEY")
+	processorCACert := os.Getenv("PROCESSOR_CA_CERT")
+
+	fmt.Printf("json library: %s\n", json.Library)
+	fmt.Printf("money library: %s\n", money.Library)
+
 	blockCh := make(chan error, 2)
-	queue := make(chan []byte, queueMaxSize)
+	q := queue.New(queueMaxSize)
+	if queueLIFOThreshold > 0 {
+		q.EnableLIFOAbove(queueLIFOThreshold)
+	}
+	q.SetMaxBytes(queueMaxBytes)
+	if url := os.Getenv("DRAIN_WEBHOOK_URL"); url != "" {
+		q.OnDrained(api.DrainWebhook(url))
+	}
 	pp := paymentProcessor.NewPaymentProcessor(ctx, redisClient)
+	if err := pp.SetSummaryPrecision(summaryPrecision); err != nil {
+		panic(err)
+	}
+	if processorClientCert != "" || processorClientKey != "" || processorCACert != "" {
+		if err := pp.EnableMutualTLS(processorClientCert, processorClientKey, processorCACert); err != nil {
+			panic(err)
+		}
+	}
+	// REDIS_KEY_PREFIX isolates runs sharing one Redis, empty keeps the plain keys
+	pp.SetKeyPrefix(ctx, os.Getenv("REDIS_KEY_PREFIX"))
+	pp.SetHealthCheckPair(ctx, os.Getenv("HEALTH_CHECK_PAIR"))
+	pp.SetColdStartUp(ctx, coldStartUp)
+	// PREFLIGHT=warn logs unreachable processors at startup, fail refuses to
+	// start, off skips the check for setups where one is down on purpose
+	switch preflight := getEnv("PREFLIGHT", "warn"); preflight {
+	case "off":
+	case "warn", "fail":
+		if err := pp.PreflightCheck(ctx); err != nil {
+			if preflight == "fail" {
+				panic(err)
+			}
+			log.Printf("warning: processor preflight failed: %v\n", err)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported PREFLIGHT %q", preflight))
+	}
+	pp.SetHealthRecoveryProbes(healthRecoveryProbes)
+	pp.SetUnreachableGrace(unreachableGrace)
+	pp.SetProcessorConcurrency(defaultMaxConcurrency, fallbackMaxConcurrency)
+	pp.SetProcessorRateLimit(defaultRateLimit, fallbackRateLimit)
+	pp.SetMaxConcurrentSummaries(maxConcurrentSummaries, summaryQueueWait)
+	if err := pp.SetRoutingPolicy(routingPolicy, rampWindow); err != nil {
+		panic(err)
+	}
+	if err := pp.SetSaveMode(saveMode); err != nil {
+		panic(err)
+	}
+	if err := pp.SetIndexScorePolicy(getEnv("INDEX_SCORE_POLICY", paymentProcessor.IndexScoreEarliest)); err != nil {
+		panic(err)
+	}
+	pp.SetSaveRetries(saveRetries, saveRetryBackoff)
+	pp.SetSuccessStatuses(defaultSuccessCodes, fallbackSuccessCodes)
+	if err := pp.SetRecordCodec(recordCodec); err != nil {
+		panic(err)
+	}
+	if disableFallback {
+		pp.DisableFallback()
+	}
+	if processorIndexes {
+		pp.EnableProcessorIndexes()
+		pp.SetTotalsFlushInterval(totalsFlushInterval)
+	}
+	if paymentTiming {
+		pp.EnableTiming()
+	}
+	if instanceTagging {
+		pp.EnableInstanceTagging(instanceID)
+	}
+	if redisClock {
+		pp.EnableRedisClock()
+	}
+	if paymentEvents {
+		pp.EnablePaymentEvents()
+	}
+	if dryRun {
+		fmt.Println("dry run: payments are not sent to the processors")
+		pp.EnableDryRun()
+	}
+	if summaryCacheEnabled {
+		pp.EnableSummaryCache(summaryCacheTTL, summaryCacheGranularity)
+	}
+	if paymentStore == "file" {
+		if err := pp.UseFileStore(paymentStoreFile); err != nil {
+			panic(err)
+		}
+	}
+	if saveBatchInterval > 0 {
+		pp.EnableWriteCoalescing(saveBatchInterval, saveBatchSize)
+	}
 
-	pw := worker.NewPaymentWorker(pp, queue, concurrency)
-	pw.StartPaymentWorker(queueMaxSize)
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	pw := worker.NewPaymentWorker(pp, q, concurrency)
+	if retryBudget > 0 {
+		pw.EnableRetryBudget(retryBudget)
+	}
+	pw.SetMaxQueueAge(queueMaxAge)
+	pw.SetMaxConnRetries(processorConnRetries)
+	pw.SetAttemptTimeout(attemptTimeout, attemptTimeoutGrowth)
+	pw.SetResultLogSampling(resultLogSample)
+	backoffJitter := getEnv("BACKOFF_JITTER", worker.JitterAdditive)
+	if err := pw.SetBackoffJitter(backoffJitter); err != nil {
+		panic(err)
+	}
+	if disableFallback {
+		pw.SetMaxRetries(strictDefaultMaxRetries)
+	}
+	if parkQueue {
+		pw.SetParkOnShutdown(true)
+		restored, err := worker.RestoreParkedQueue(ctx, pp, q)
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("parked tasks restored: %d\n", restored)
+	}
+	pw.StartPaymentWorker(workersCtx, queueMaxSize)
+
+	rbw := worker.NewRedisBatchWorker(pp, concurrency, queueBatchSize)
+	rbw.SetAttemptTimeout(attemptTimeout, attemptTimeoutGrowth)
+	rbw.SetResultLogSampling(resultLogSample)
+	if err := rbw.SetBackoffJitter(backoffJitter); err != nil {
+		panic(err)
+	}
+	if disableFallback {
+		rbw.SetMaxRetries(strictDefaultMaxRetries)
+	}
+	if queueBackend == "redis" {
+		rbw.StartRedisBatchWorker(workersCtx)
+	}
+
+	rhw := worker.NewRedisHealthPool(pp)
+	rhw.StartRedisHealthWorker(workersCtx, redisHealthInterval)
 
 	hcw := worker.NewHealthCheckPool(pp)
-	hcw.StartHealthCheckWorker(master)
+	hcw.SetProbeOffset(healthProbeOffset)
+	hcw.StartHealthCheckWorker(workersCtx, master)
+
+	cw := worker.NewCompactionPool(pp)
+	if master && compactionRetention > 0 {
+		cw.StartCompactionWorker(workersCtx, compactionInterval, compactionRetention)
+	}
 
-	httpServer := api.Setup(pp, queue)
+	csw := worker.NewClockSyncPool(pp)
+	if redisClock {
+		csw.StartClockSyncWorker(workersCtx, redisClockInterval)
+	}
+
+	ow := worker.NewOverflowPool(pp, q)
+	if overflowToRedis {
+		ow.StartOverflowWorker(workersCtx)
+	}
+
+	httpServer := api.Setup(pp, q, api.Config{
+		StrictPayloads:             strictPayloads,
+		AllowProcessorOverride:     allowProcessorOverride,
+		CorrelationIDNormalization: getEnv("CORRELATION_ID_NORMALIZATION", "trim"),
+		ValidateCorrelationID:      validateCorrelationID,
+		OverflowToRedis:            overflowToRedis,
+		AdminToken:                 os.Getenv("ADMIN_TOKEN"),
+		RedisQueue:                 queueBackend == "redis",
+		MaxAmount:                  maxAmount,
+		AuditAmount:                auditAmount,
+		InboundRateLimit:           inboundRateLimit,
+		InboundBurst:               inboundBurst,
+		InboundClientHeader:        os.Getenv("INBOUND_CLIENT_HEADER"),
+		SwapReversedSummaryRange:   swapReversedSummaryRange,
+		PaymentWorkers:             pw,
+		ReadHeaderTimeout:          readHeaderTimeout,
+		ReadTimeout:                readTimeout,
+		AcceptedStatus:             acceptedStatus,
+		SyncPayments:               syncPayments,
+		FixedAmountDecimals:        fixedAmountDecimals,
+	})
 	go func() {
 		err := httpServer.ListenAndServe()
 		if err != nil {
@@ -77,19 +582,87 @@ func main() {
 		}
 	}()
 
+	// SIGHUP re-reads the processor URLs without a restart
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			pp.ReloadConfig()
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	close(queue)
 	log.Println("shutting down servers...")
 
-	defer cancel()
-	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("http server shutdown failed: %v", err)
+	cancel()
+	// the startup ctx has long expired, give open connections their own time
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		// keep draining, queued tasks and buffered totals must still be saved
+		log.Printf("http server shutdown failed: %v\n", err)
 	}
+
+	q.Close()
+	stopWorkers()
+	pw.Wait()
+	if parkQueue {
+		parked, err := worker.ParkQueue(context.Background(), pp, q)
+		if err != nil {
+			log.Printf("failed to park queued tasks: %v\n", err)
+		}
+		log.Printf("queued tasks parked: %d\n", parked)
+	}
+	rbw.Wait()
+	if queueBackend == "redis" {
+		log.Printf("redis queue tasks requeued: %d\n", rbw.Requeued())
+	}
+	ow.Wait()
+	hcw.Wait()
+	rhw.Wait()
+	cw.Wait()
+	csw.Wait()
+	pp.Close()
 	log.Println("server exiting.")
 }
 
+// resolveConcurrency reads CONCURRENCY, where "auto" means multiplier workers
+// per GOMAXPROCS, as they spend most of their time waiting on I/O.
+func resolveConcurrency(value, multiplier string) (int, error) {
+	if value != "auto" {
+		return strconv.Atoi(value)
+	}
+
+	perProc, err := strconv.Atoi(multiplier)
+	if err != nil {
+		return 0, err
+	}
+	return max(runtime.GOMAXPROCS(0)*perProc, 1), nil
+}
+
+// parseStatusCodes reads a comma-separated list of HTTP statuses. An empty
+// list returns nil.
+func parseStatusCodes(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var codes []int
+	for _, part := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if code < 200 || code > 299 {
+			return nil, fmt.Errorf("success status %d is not a 2xx", code)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if len(value) == 0 {
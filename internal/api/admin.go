@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+)
+
+// authorizeAdmin checks the admin token and writes the error response when
+// the request may not go on.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request, cfg Config) bool {
+	if cfg.AdminToken == "" {
+		writeError(w, http.StatusNotFound, "not_enabled", "admin endpoints are not enabled")
+		return false
+	}
+	if r.Header.Get("X-Rinha-Token") != cfg.AdminToken {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid admin token")
+		return false
+	}
+	return true
+}
+
+// adminPauseHandler stops or resumes accepting payments. Workers keep draining
+// whatever was already queued either way.
+func adminPauseHandler(paused *atomic.Bool, pause bool, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if !authorizeAdmin(w, r, cfg) {
+			return
+		}
+
+		if paused.Swap(pause) != pause {
+			fmt.Printf("ingestion paused: %t\n", pause)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// adminSnapshotHandler precomputes the summary so the following
+// /payments-summary calls are served from memory until a new payment lands.
+func adminSnapshotHandler(p *paymentProcessor.PaymentProcessor, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if !authorizeAdmin(w, r, cfg) {
+			return
+		}
+
+		takenAt, err := p.TakeSummarySnapshot(r.Context())
+		if err != nil {
+			fmt.Println("failed to take summary snapshot:", err)
+			writeError(w, http.StatusInternalServerError, "snapshot_failed", "failed to take summary snapshot")
+			return
+		}
+		w.Header().Set("X-Summary-Snapshot", takenAt.Format(time.RFC3339Nano))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+const (
+	defaultDeadLetterLimit = 100
+	maxDeadLetterLimit     = 1000
+)
+
+type deadLetterResponse struct {
+	Total   int64                    `json:"total"`
+	Offset  int64                    `json:"offset"`
+	Entries []models.DeadLetterEntry `json:"entries"`
+}
+
+// deadLetterHandler lists parked tasks, oldest first, without removing them.
+func deadLetterHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		q := r.URL.Query()
+		limit := int64(defaultDeadLetterLimit)
+		if q.Has("limit") {
+			var err error
+			limit, err = strconv.ParseInt(q.Get("limit"), 10, 64)
+			if err != nil || limit < 1 || limit > maxDeadLetterLimit {
+				writeError(w, http.StatusBadRequest, "invalid_limit", fmt.Sprintf("limit must be between 1 and %d", maxDeadLetterLimit))
+				return
+			}
+		}
+		var offset int64
+		if q.Has("offset") {
+			var err error
+			offset, err = strconv.ParseInt(q.Get("offset"), 10, 64)
+			if err != nil || offset < 0 {
+				writeError(w, http.StatusBadRequest, "invalid_offset", "offset must be a non-negative integer")
+				return
+			}
+		}
+
+		entries, total, err := p.DeadLetters(r.Context(), offset, limit)
+		if err != nil {
+			fmt.Println(err)
+			writeError(w, http.StatusInternalServerError, "dead_letter_failed", "failed to get dead letters")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deadLetterResponse{
+			Total:   total,
+			Offset:  offset,
+			Entries: entries,
+		})
+	}
+}
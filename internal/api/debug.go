@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	worker "github.com/payment-processor-rinha/internal/application/payment/workers"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+type queueStatsResponse struct {
+	Depth          int     `json:"depth"`
+	Bytes          int64   `json:"bytes"`
+	InFlight       int     `json:"inFlight"`
+	LastAcceptedAt string  `json:"lastAcceptedAt,omitempty"`
+	DrainedAt      string  `json:"drainedAt,omitempty"`
+	TimeToDrainMs  float64 `json:"timeToDrainMs"`
+}
+
+func newQueueStatsResponse(stats queue.DrainStats) queueStatsResponse {
+	res := queueStatsResponse{
+		Depth:         stats.Depth,
+		Bytes:         stats.Bytes,
+		InFlight:      stats.InFlight,
+		TimeToDrainMs: float64(stats.TimeToDrain.Microseconds()) / 1000,
+	}
+	if !stats.LastAcceptedAt.IsZero() {
+		res.LastAcceptedAt = stats.LastAcceptedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if !stats.DrainedAt.IsZero() {
+		res.DrainedAt = stats.DrainedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return res
+}
+
+func debugQueueHandler(q *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newQueueStatsResponse(q.DrainStats()))
+	}
+}
+
+// debugTimingHandler breaks down where payments in the from/to window spent
+// their time, for payments saved while timing was enabled.
+func debugTimingHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		q := r.URL.Query()
+		from := parseRequestedAt(q.Get("from")).UTC().UnixMilli()
+		to := parseRequestedAt(q.Get("to")).UTC().UnixMilli()
+		res, err := p.TimingBreakdown(r.Context(), from, to)
+		if err != nil {
+			fmt.Println("failed to get timing breakdown:", err)
+			writeError(w, http.StatusInternalServerError, "timing_failed", "failed to get timing breakdown")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// debugInstancesHandler splits the from/to summary by processing instance, for
+// payments saved while instance tagging was enabled.
+func debugInstancesHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		q := r.URL.Query()
+		from := parseRequestedAt(q.Get("from")).UTC().UnixMilli()
+		to := parseRequestedAt(q.Get("to")).UTC().UnixMilli()
+		res, err := p.InstanceBreakdown(r.Context(), from, to)
+		if err != nil {
+			fmt.Println("failed to get instance breakdown:", err)
+			writeError(w, http.StatusInternalServerError, "instances_failed", "failed to get instance breakdown")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func debugWorkersHandler(pw *worker.PaymentWorkerPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		if pw == nil {
+			writeError(w, http.StatusNotFound, "not_enabled", "payment workers are not running")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pw.States())
+	}
+}
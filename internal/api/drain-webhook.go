@@ -0,0 +1,33 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+const drainWebhookTimeout = 2 * time.Second
+
+// DrainWebhook returns a queue.OnDrained callback posting the drain stats as
+// JSON to url.
+func DrainWebhook(url string) func(queue.DrainStats) {
+	client := &http.Client{Timeout: drainWebhookTimeout}
+	return func(stats queue.DrainStats) {
+		body, err := json.Marshal(newQueueStatsResponse(stats))
+		if err != nil {
+			fmt.Println("failed to marshal drain stats:", err)
+			return
+		}
+
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			fmt.Println("failed to call drain webhook:", err)
+			return
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Error: message,
+		Code:  code,
+	})
+}
+
+// unsupportedStore answers endpoints that need payments saved to Redis while
+// they go to the file store.
+func unsupportedStore(w http.ResponseWriter) {
+	writeError(w, http.StatusNotImplemented, "unsupported_store", "Not available with PAYMENT_STORE=file")
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method Not Allowed")
+}
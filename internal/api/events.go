@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+)
+
+// paymentEventsHandler streams saved payments as Server-Sent Events until the
+// client goes away or the server shuts down, as Shutdown would otherwise wait
+// on every open stream.
+func paymentEventsHandler(p *paymentProcessor.PaymentProcessor, shutdown context.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		if !p.PaymentEventsEnabled() {
+			writeError(w, http.StatusNotFound, "not_enabled", "payment events are not enabled")
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, "streaming_unsupported", "streaming is not supported")
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(shutdown, cancel)
+		defer stop()
+
+		events := p.SubscribePaymentEvents(ctx)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for event := range events {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
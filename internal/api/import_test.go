@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+func TestPaymentsImportHandlerRequiresAdminToken(t *testing.T) {
+	record := `{"version":1,"correlationId":"00000000-0000-0000-0000-000000000001","requestedAt":"2025-07-01T12:00:00Z","amount":19.9,"onDefault":true}`
+
+	tests := []struct {
+		name       string
+		adminToken string
+		token      string
+		wantStatus int
+	}{
+		{name: "admin disabled", adminToken: "", token: "", wantStatus: http.StatusNotFound},
+		{name: "no token", adminToken: "secret", token: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", adminToken: "secret", token: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "admin token", adminToken: "secret", token: "secret", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(t)
+			req := httptest.NewRequest(http.MethodPost, "/payments/import", strings.NewReader(record+"\n{\n"))
+			if tt.token != "" {
+				req.Header.Set("X-Rinha-Token", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			paymentsImportHandler(p, Config{AdminToken: tt.adminToken}).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			imported := rec.Code == http.StatusOK
+			if _, err := p.GetPayment(context.Background(), "00000000-0000-0000-0000-000000000001"); (err == nil) != imported {
+				t.Errorf("payment stored = %t, want %t", err == nil, imported)
+			}
+			if !imported {
+				return
+			}
+
+			res := models.ImportResult{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Imported != 1 || res.Skipped != 1 || len(res.Errors) != 1 || res.Errors[0].Line != 2 {
+				t.Errorf("import result = %+v, want one imported and line 2 skipped", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// clientLimiterIdle is how long a client's bucket is kept after its last request.
+const clientLimiterIdle = time.Minute
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// clientLimiter keeps one token bucket per client, identified by a request
+// header or, without one, the remote IP. Behind nginx the remote IP is nginx
+// itself, so a header such as X-Real-IP is what actually tells clients apart.
+type clientLimiter struct {
+	limit     rate.Limit
+	burst     int
+	header    string
+	buckets   map[string]*clientBucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+func newClientLimiter(perSecond float64, burst int, header string) *clientLimiter {
+	return &clientLimiter{
+		limit:     rate.Limit(perSecond),
+		burst:     max(burst, 1),
+		header:    header,
+		buckets:   make(map[string]*clientBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *clientLimiter) allow(r *http.Request) bool {
+	client := l.clientKey(r)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > clientLimiterIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > clientLimiterIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &clientBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[client] = b
+	}
+	b.lastSeen = now
+	return b.limiter.AllowN(now, 1)
+}
+
+func (l *clientLimiter) clientKey(r *http.Request) string {
+	if l.header != "" {
+		if v := r.Header.Get(l.header); v != "" {
+			return v
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,60 +1,270 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"time"
 
-	jsoniter "github.com/json-iterator/go"
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
 	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	worker "github.com/payment-processor-rinha/internal/application/payment/workers"
+	"github.com/payment-processor-rinha/internal/json"
 )
 
-var json = jsoniter.ConfigFastest
+type Config struct {
+	// StrictPayloads rejects payment bodies carrying fields we don't know about.
+	StrictPayloads bool
+	// AllowProcessorOverride honors X-Force-Processor on /payments, for experiments only.
+	AllowProcessorOverride bool
+	// CorrelationIDNormalization canonicalizes correlationId before enqueueing so
+	// the same payment always maps to the same Redis key: "trim" strips
+	// surrounding whitespace, "lower" also lowercases it, "none" leaves it as sent.
+	CorrelationIDNormalization string
+	// ValidateCorrelationID rejects payments whose correlationId is not a UUID.
+	ValidateCorrelationID bool
+	// OverflowToRedis parks payments in Redis instead of rejecting them when the queue is full.
+	OverflowToRedis bool
+	// RedisQueue enqueues payments on the Redis-backed queue instead of in memory.
+	RedisQueue bool
+	// AdminToken enables /reconcile, /payments/import and /admin/*. Callers
+	// must send it and it is forwarded to the processors' admin endpoints.
+	AdminToken string
+	// MaxAmount rejects payments above it with 400. Zero disables the check.
+	MaxAmount float64
+	// AuditAmount logs accepted payments above it to a Redis list. Zero disables it.
+	AuditAmount float64
+	// InboundRateLimit caps accepted payments per second per client, with
+	// InboundBurst on top. Zero disables it.
+	InboundRateLimit float64
+	InboundBurst     int
+	// InboundClientHeader identifies clients for InboundRateLimit, falling
+	// back to the remote IP when empty or missing.
+	InboundClientHeader string
+	// SwapReversedSummaryRange answers a summary whose from is after to as if
+	// they were the other way round, instead of rejecting it.
+	SwapReversedSummaryRange bool
+	// PaymentWorkers enables /debug/workers.
+	PaymentWorkers *worker.PaymentWorkerPool
+	// ReadHeaderTimeout and ReadTimeout cut off clients trickling the headers
+	// or the whole request, body included. main defaults them to 2s and 5s,
+	// zero means no limit.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// AcceptedStatus is what /payments answers once a payment is queued.
+	// 202 (the default in main) also points Location at /payments/{correlationId},
+	// 201 and 200 answer with no Location for clients expecting the old reply.
+	AcceptedStatus int
+	// SyncPayments processes payments within the /payments request and only
+	// answers 201 once they are recorded, bypassing the queue.
+	SyncPayments bool
+	// FixedAmountDecimals writes summary amounts as strings with exactly that
+	// many decimals, "12.50" instead of 12.5. Zero keeps plain numbers.
+	FixedAmountDecimals int
+}
+
+func Setup(pp *paymentProcessor.PaymentProcessor, q *queue.Queue, cfg Config) *http.Server {
+	paused := &atomic.Bool{}
 
-func Setup(pp *paymentProcessor.PaymentProcessor, queue chan []byte) *http.Server {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/payments", paymentHandler(queue))
-	mux.HandleFunc("/payments-summary", paymentsSummaryHandler(pp))
+	mux.HandleFunc("/payments", paymentHandler(pp, q, paused, cfg))
+	mux.HandleFunc("/payments-summary", paymentsSummaryHandler(pp, q, cfg))
+	mux.HandleFunc("/payments-summary/timeseries", paymentsSummaryTimeseriesHandler(pp))
+	mux.HandleFunc("/payments/export", paymentsExportHandler(pp))
+	mux.HandleFunc("/payments/import", paymentsImportHandler(pp, cfg))
+	mux.HandleFunc("/payments/{correlationId}", getPaymentHandler(pp))
+	shutdown, stopStreams := context.WithCancel(context.Background())
+	mux.HandleFunc("/events", paymentEventsHandler(pp, shutdown))
+	mux.HandleFunc("/dead-letter", deadLetterHandler(pp))
+	mux.HandleFunc("/reconcile", reconcileHandler(pp, cfg))
+	mux.HandleFunc("/admin/pause", adminPauseHandler(paused, true, cfg))
+	mux.HandleFunc("/admin/resume", adminPauseHandler(paused, false, cfg))
+	mux.HandleFunc("/admin/snapshot", adminSnapshotHandler(pp, cfg))
+	mux.HandleFunc("/debug/processor", debugProcessorHandler(pp))
+	mux.HandleFunc("/debug/redis", debugRedisHandler(pp))
+	mux.HandleFunc("/debug/queue", debugQueueHandler(q))
+	mux.HandleFunc("/debug/timing", debugTimingHandler(pp))
+	mux.HandleFunc("/debug/instances", debugInstancesHandler(pp))
+	mux.HandleFunc("/debug/workers", debugWorkersHandler(cfg.PaymentWorkers))
 
 	fmt.Println("starting server running on port 9999")
-	return &http.Server{
-		Addr:    ":9999",
-		Handler: mux,
+	server := &http.Server{
+		Addr:              ":9999",
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
 	}
+	server.RegisterOnShutdown(stopStreams)
+	return server
 }
 
-func paymentHandler(queue chan []byte) http.HandlerFunc {
+func paymentHandler(p *paymentProcessor.PaymentProcessor, q *queue.Queue, paused *atomic.Bool, cfg Config) http.HandlerFunc {
+	var limiter *clientLimiter
+	if cfg.InboundRateLimit > 0 {
+		limiter = newClientLimiter(cfg.InboundRateLimit, cfg.InboundBurst, cfg.InboundClientHeader)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			w.Header().Set("Allow", http.MethodPost)
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if paused.Load() {
+			writeError(w, http.StatusServiceUnavailable, "ingestion_paused", "Payments are paused")
+			return
+		}
+		if limiter != nil && !limiter.allow(r) {
+			writeError(w, http.StatusTooManyRequests, "rate_limited", "Too many payments")
 			return
 		}
 		defer r.Body.Close()
 
 		task, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+			// ReadTimeout ran out while the client was still sending
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				writeError(w, http.StatusRequestTimeout, "request_timeout", "Request body took too long")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "invalid_body", "Failed to read request body")
+			return
+		}
+		// the worker can't do anything with it, so don't let it reach the queue
+		if len(bytes.TrimSpace(task)) == 0 {
+			writeError(w, http.StatusBadRequest, "empty_body", "Request body is empty")
 			return
 		}
 
-		select {
-		case queue <- task:
-		default:
-			http.Error(w, "Queue is full", http.StatusServiceUnavailable)
+		if cfg.StrictPayloads {
+			if err := decodeStrict(task); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_payload", err.Error())
+				return
+			}
+		}
+
+		if cfg.CorrelationIDNormalization != "" && cfg.CorrelationIDNormalization != "none" {
+			task, err = normalizeCorrelationID(task, cfg.CorrelationIDNormalization == "lower")
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_payload", "invalid payment payload")
+				return
+			}
+		}
+
+		payload := tasks.ProcessPaymentTask{}
+		if err := json.Unmarshal(task, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_payload", "invalid payment payload")
+			return
+		}
+		// the body is queued as sent, so a forcedProcessor in it would skip the
+		// override gate below
+		if payload.ForcedProcessor != "" {
+			writeError(w, http.StatusBadRequest, "invalid_payload", "forcedProcessor can only be set through X-Force-Processor")
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
+		if cfg.ValidateCorrelationID && !isUUID(payload.CorrelationId) {
+			writeError(w, http.StatusBadRequest, "invalid_correlation_id", "correlationId must be a UUID")
+			return
+		}
+		if cfg.MaxAmount > 0 && payload.Amount > cfg.MaxAmount {
+			writeError(w, http.StatusBadRequest, "amount_too_large", fmt.Sprintf("amount must not exceed %g", cfg.MaxAmount))
+			return
+		}
+		if cfg.AuditAmount > 0 && payload.Amount > cfg.AuditAmount {
+			if err := p.AuditLargePayment(r.Context(), payload.ProcessPaymentPayload); err != nil {
+				fmt.Println("failed to audit large payment:", err)
+			}
+		}
+
+		if forced := r.Header.Get("X-Force-Processor"); cfg.AllowProcessorOverride && forced != "" {
+			task, err = forceProcessor(payload, forced)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_processor", err.Error())
+				return
+			}
+		}
+
+		if cfg.SyncPayments {
+			processPaymentNow(w, r, p, task)
+			return
+		}
+
+		if cfg.RedisQueue {
+			if err := p.PushQueue(r.Context(), task); err != nil {
+				fmt.Println(err)
+				writeError(w, http.StatusServiceUnavailable, "queue_unavailable", "Queue is unavailable")
+				return
+			}
+			accepted(w, cfg, payload.CorrelationId)
+			return
+		}
+
+		if !q.TryPush(task) {
+			if !cfg.OverflowToRedis {
+				writeError(w, http.StatusServiceUnavailable, "queue_full", "Queue is full")
+				return
+			}
+			if err := p.PushOverflow(r.Context(), task); err != nil {
+				fmt.Println(err)
+				writeError(w, http.StatusServiceUnavailable, "queue_full", "Queue is full")
+				return
+			}
+		}
+		accepted(w, cfg, payload.CorrelationId)
 	}
 }
 
-func paymentsSummaryHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+// accepted answers a queued payment. A 202 links to where the processed
+// payment will show up.
+func accepted(w http.ResponseWriter, cfg Config, correlationId string) {
+	if cfg.AcceptedStatus == http.StatusAccepted {
+		w.Header().Set("Location", "/payments/"+url.PathEscape(correlationId))
+	}
+	w.WriteHeader(cfg.AcceptedStatus)
+}
+
+func decodeStrict(body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	payload := tasks.ProcessPaymentPayload{}
+	if err := decoder.Decode(&payload); err != nil {
+		return fmt.Errorf("invalid payment payload: %w", err)
+	}
+	return nil
+}
+
+func forceProcessor(task tasks.ProcessPaymentTask, processor string) ([]byte, error) {
+	if processor != tasks.ProcessorDefault && processor != tasks.ProcessorFallback {
+		return nil, fmt.Errorf("unknown processor %q", processor)
+	}
+
+	task.ForcedProcessor = processor
+	return json.Marshal(task)
+}
+
+const drainTimeout = 5 * time.Second
+
+func paymentsSummaryHandler(p *paymentProcessor.PaymentProcessor, pending *queue.Queue, cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
 		if r.Method != http.MethodGet {
-			w.Header().Set("Allow", http.MethodGet)
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		w.Header().Set("Vary", "Accept")
+		contentType, ok := negotiateSummaryType(r.Header.Get("Accept"))
+		if !ok {
+			writeError(w, http.StatusNotAcceptable, "not_acceptable", "summary is available as application/json or text/csv")
 			return
 		}
 
@@ -62,17 +272,265 @@ func paymentsSummaryHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFu
 		from := parseRequestedAt(q.Get("from")).UTC().UnixMilli()
 		to := parseRequestedAt(q.Get("to")).UTC().UnixMilli()
 		fmt.Printf("from %d to %d\n", from, to)
-		res, err := p.SummaryPayments(r.Context(), from, to)
+		// a missing bound parses as the zero time, only compare real ones
+		if q.Has("from") && q.Has("to") && from > to {
+			if !cfg.SwapReversedSummaryRange {
+				writeError(w, http.StatusBadRequest, "invalid_range", "from must not be after to")
+				return
+			}
+			from, to = to, from
+		}
+		if q.Get("waitForDrain") == "true" {
+			drained := pending.WaitDrained(r.Context(), drainTimeout) &&
+				p.WaitSaved(r.Context(), drainTimeout)
+			w.Header().Set("X-Queue-Drained", strconv.FormatBool(drained))
+		}
+
+		opts := paymentProcessor.SummaryOptions{
+			Latency:   q.Get("latency") == "true",
+			OmitEmpty: q.Get("omitEmpty") == "true",
+		}
+		// outages change without any payment being saved, so no ETag for them
+		withOutages := q.Get("outages") == "true"
+
+		var etag string
+		var err error
+		if !withOutages {
+			if etag, err = p.SummaryETag(r.Context(), from, to, opts); err != nil {
+				fmt.Println(err)
+			}
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		res, takenAt, fromSnapshot := p.SnapshotSummary(r.Context(), from, to, opts)
+		if fromSnapshot {
+			w.Header().Set("X-Summary-Snapshot", takenAt.Format(time.RFC3339Nano))
+		} else {
+			res, err = p.SummaryPayments(r.Context(), from, to, opts)
+			if errors.Is(err, paymentProcessor.ErrSummaryBusy) {
+				writeError(w, http.StatusTooManyRequests, "summary_busy", "Too many summaries in progress")
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "summary_failed", "failed to get payments summary")
+				return
+			}
+		}
+
+		if withOutages {
+			outages, err := p.Outages(r.Context(), from, to)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "summary_failed", "failed to get processor outages")
+				return
+			}
+			// res may be shared with the summary cache or snapshot
+			withGaps := *res
+			withGaps.Outages = outages
+			res = &withGaps
+		}
+
+		if contentType == contentTypeCSV {
+			writeSummaryCSV(w, res, opts, cfg.FixedAmountDecimals)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if opts.OmitEmpty {
+			json.NewEncoder(w).Encode(compactSummary(res, cfg))
+			return
+		}
+		json.NewEncoder(w).Encode(summaryResponse(res, cfg))
+	}
+}
+
+// compactSummary drops the processors that handled no payment in the window.
+func compactSummary(res *models.PaymentsSummaryResponse, cfg Config) map[string]any {
+	compact := make(map[string]any, 4)
+	if res.Default.TotalRequests > 0 {
+		compact["default"] = summaryBody(res.Default, cfg)
+	}
+	if res.Fallback.TotalRequests > 0 {
+		compact["fallback"] = summaryBody(res.Fallback, cfg)
+	}
+	if res.DryRun != nil && res.DryRun.TotalRequests > 0 {
+		compact["dryRun"] = summaryBody(*res.DryRun, cfg)
+	}
+	if len(res.Outages) > 0 {
+		compact["outages"] = res.Outages
+	}
+	return compact
+}
+
+func paymentsExportHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		q := r.URL.Query()
+		from, to := "-inf", "+inf"
+		if q.Has("from") {
+			from = fmt.Sprint(parseRequestedAt(q.Get("from")).UTC().UnixMilli())
+		}
+		if q.Has("to") {
+			to = fmt.Sprint(parseRequestedAt(q.Get("to")).UTC().UnixMilli())
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err := p.ExportPayments(r.Context(), from, to, func(record []byte) error {
+			if _, err := w.Write(record); err != nil {
+				return err
+			}
+			_, err := w.Write([]byte("\n"))
+			return err
+		})
+		if errors.Is(err, paymentProcessor.ErrUnsupportedStore) {
+			unsupportedStore(w)
+			return
+		}
+		if err != nil {
+			fmt.Println("failed to export payments:", err)
+		}
+	}
+}
+
+func getPaymentHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		record, err := p.GetPayment(r.Context(), r.PathValue("correlationId"))
+		if errors.Is(err, paymentProcessor.ErrPaymentNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "payment not found")
+			return
+		}
+		if errors.Is(err, paymentProcessor.ErrUnsupportedStore) {
+			unsupportedStore(w)
+			return
+		}
+		if err != nil {
+			fmt.Println("failed to get payment:", err)
+			writeError(w, http.StatusInternalServerError, "lookup_failed", "failed to get payment")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(record)
+	}
+}
+
+// paymentsImportHandler writes records straight into Redis, so like the other
+// admin endpoints it needs the admin token.
+func paymentsImportHandler(p *paymentProcessor.PaymentProcessor, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if !authorizeAdmin(w, r, cfg) {
+			return
+		}
+		defer r.Body.Close()
+
+		res, err := p.ImportPayments(r.Context(), r.Body)
+		if errors.Is(err, paymentProcessor.ErrUnsupportedStore) {
+			unsupportedStore(w)
+			return
+		}
+		if err != nil {
+			fmt.Println("failed to import payments:", err)
+			writeError(w, http.StatusInternalServerError, "import_failed", "failed to import payments")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func reconcileHandler(p *paymentProcessor.PaymentProcessor, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		if !authorizeAdmin(w, r, cfg) {
+			return
+		}
+
+		q := r.URL.Query()
+		from := parseRequestedAt(q.Get("from"))
+		to := parseRequestedAt(q.Get("to"))
+		res, err := p.Reconcile(r.Context(), from, to, cfg.AdminToken)
 		if err != nil {
-			http.Error(w, "failed to get payments summary", http.StatusInternalServerError)
+			fmt.Println("failed to reconcile:", err)
+			writeError(w, http.StatusBadGateway, "reconcile_failed", "failed to reconcile payments")
 			return
 		}
 
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(res)
 	}
 }
 
+// debugProcessorHandler forces the processor up (?up=true) or down (?up=false)
+// regardless of health checks, and ?up=auto hands control back to them.
+func debugProcessorHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+
+		up := r.URL.Query().Get("up")
+		if up == "auto" {
+			p.ClearUpOverride()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		status, err := strconv.ParseBool(up)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_parameter", "up must be true, false or auto")
+			return
+		}
+		p.OverrideUp(status)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func debugRedisHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		health := p.RedisHealth(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if health.Error != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(health)
+	}
+}
+
+// parseRequestedAt reads a timestamp as RFC3339, or as unix millis when it is
+// a plain integer.
 func parseRequestedAt(reqAt string) time.Time {
+	if millis, err := strconv.ParseInt(reqAt, 10, 64); err == nil {
+		return time.UnixMilli(millis)
+	}
+
 	parsedTime, err := time.Parse(time.RFC3339, reqAt)
 	if err != nil {
 		fmt.Printf("invalid 'from' date format: %v\n", err)
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+	"github.com/redis/go-redis/v9"
+)
+
+const testPayment = `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9}`
+
+func postPayment(t *testing.T, cfg Config, q *queue.Queue, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	if cfg.AcceptedStatus == 0 {
+		cfg.AcceptedStatus = http.StatusAccepted
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	paymentHandler(nil, q, &atomic.Bool{}, cfg).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPaymentHandlerRejectsForcedProcessorInBody(t *testing.T) {
+	bodies := []string{
+		`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"forcedProcessor":"fallback"}`,
+		`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"forcedProcessor":"xyz"}`,
+		// the decoder matches field names regardless of case
+		`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"FORCEDPROCESSOR":"fallback"}`,
+	}
+	for _, allowOverride := range []bool{false, true} {
+		for _, body := range bodies {
+			q := queue.New(10)
+			rec := postPayment(t, Config{AllowProcessorOverride: allowOverride}, q, body, nil)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("override %t, body %s: status = %d, want %d", allowOverride, body, rec.Code, http.StatusBadRequest)
+			}
+			if q.Len() != 0 {
+				t.Errorf("override %t, body %s: %d tasks queued, want none", allowOverride, body, q.Len())
+			}
+		}
+	}
+}
+
+func TestPaymentHandlerForcesProcessorThroughHeader(t *testing.T) {
+	header := http.Header{"X-Force-Processor": {"fallback"}}
+
+	tests := []struct {
+		name          string
+		allowOverride bool
+		wantForced    bool
+	}{
+		{name: "override disabled", allowOverride: false, wantForced: false},
+		{name: "override enabled", allowOverride: true, wantForced: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := queue.New(10)
+			rec := postPayment(t, Config{AllowProcessorOverride: tt.allowOverride}, q, testPayment, header)
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+			}
+
+			item, ok := q.Pop()
+			if !ok {
+				t.Fatal("payment was not queued")
+			}
+			forced := strings.Contains(string(item.Data), `"forcedProcessor":"fallback"`)
+			if forced != tt.wantForced {
+				t.Errorf("queued %s, forced = %t, want %t", item.Data, forced, tt.wantForced)
+			}
+		})
+	}
+}
+
+func TestPaymentHandlerStrictPayloads(t *testing.T) {
+	body := `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9,"correlation_id":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"}`
+
+	tests := []struct {
+		name       string
+		strict     bool
+		body       string
+		wantStatus int
+		wantQueued int
+	}{
+		{name: "strict rejects unknown field", strict: true, body: body, wantStatus: http.StatusBadRequest},
+		{name: "lenient ignores unknown field", strict: false, body: body, wantStatus: http.StatusAccepted, wantQueued: 1},
+		{name: "strict accepts known fields", strict: true, body: testPayment, wantStatus: http.StatusAccepted, wantQueued: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := queue.New(10)
+			rec := postPayment(t, Config{StrictPayloads: tt.strict}, q, tt.body, nil)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "invalid_payload") {
+				t.Errorf("answered %s, want an invalid_payload error", rec.Body)
+			}
+			if q.Len() != tt.wantQueued {
+				t.Errorf("%d tasks queued, want %d", q.Len(), tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestPaymentHandlerRejectsEmptyBody(t *testing.T) {
+	for _, body := range []string{"", " \n\t"} {
+		q := queue.New(10)
+		rec := postPayment(t, Config{}, q, body, nil)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %q: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+		if !strings.Contains(rec.Body.String(), "empty_body") {
+			t.Errorf("body %q: answered %s, want an empty_body error", body, rec.Body)
+		}
+		if q.Len() != 0 {
+			t.Errorf("body %q: %d tasks queued, want none", body, q.Len())
+		}
+	}
+}
+
+var testRequestedAt = time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestProcessor returns a processor saving to an in-memory Redis, holding
+// one payment requested at testRequestedAt.
+func newTestProcessor(t *testing.T) *paymentProcessor.PaymentProcessor {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	p := paymentProcessor.NewPaymentProcessor(context.Background(), client)
+
+	record, err := json.Marshal(models.PaymentRecord{
+		Version: models.PaymentRecordVersion,
+		ProcessPaymentTask: tasks.ProcessPaymentTask{
+			ProcessPaymentPayload: tasks.ProcessPaymentPayload{
+				CorrelationId: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+				RequestedAt:   testRequestedAt.Format(time.RFC3339Nano),
+				Amount:        19.9,
+			},
+			OnDefault: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ImportPayments(context.Background(), strings.NewReader(string(record))); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPaymentsSummaryHandlerReversedRange(t *testing.T) {
+	// to an hour before from
+	target := "/payments-summary?from=" + testRequestedAt.Add(time.Hour).Format(time.RFC3339) +
+		"&to=" + testRequestedAt.Add(-time.Hour).Format(time.RFC3339)
+
+	t.Run("rejected", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		paymentsSummaryHandler(newTestProcessor(t), queue.New(10), Config{}).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+		if !strings.Contains(rec.Body.String(), "invalid_range") {
+			t.Errorf("body = %s, want an invalid_range error", rec.Body)
+		}
+	})
+
+	t.Run("swapped", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		paymentsSummaryHandler(newTestProcessor(t), queue.New(10), Config{SwapReversedSummaryRange: true}).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		res := models.PaymentsSummaryResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Default.TotalRequests != 1 {
+			t.Errorf("summary = %s, want the payment in the swapped range", rec.Body)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"strconv"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+// fixedAmountSummary is a PaymentsSummary whose amount is written as a string
+// with a fixed number of decimals, "12.50" rather than 12.5.
+type fixedAmountSummary struct {
+	TotalRequests int                    `json:"totalRequests"`
+	TotalAmount   string                 `json:"totalAmount"`
+	Latency       *models.LatencySummary `json:"latency,omitempty"`
+}
+
+type fixedAmountResponse struct {
+	Default  fixedAmountSummary    `json:"default"`
+	Fallback fixedAmountSummary    `json:"fallback"`
+	DryRun   *fixedAmountSummary   `json:"dryRun,omitempty"`
+	Outages  []models.OutageWindow `json:"outages,omitempty"`
+}
+
+func formatAmount(amount float64, decimals int) string {
+	if decimals <= 0 {
+		return strconv.FormatFloat(amount, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(amount, 'f', decimals, 64)
+}
+
+func withFixedAmount(s models.PaymentsSummary, decimals int) fixedAmountSummary {
+	return fixedAmountSummary{
+		TotalRequests: s.TotalRequests,
+		TotalAmount:   formatAmount(s.TotalAmount, decimals),
+		Latency:       s.Latency,
+	}
+}
+
+// summaryBody is what the JSON summary encodes for one processor, depending
+// on Config.FixedAmountDecimals.
+func summaryBody(s models.PaymentsSummary, cfg Config) any {
+	if cfg.FixedAmountDecimals <= 0 {
+		return s
+	}
+	return withFixedAmount(s, cfg.FixedAmountDecimals)
+}
+
+// summaryResponse is what the JSON summary encodes, depending on
+// Config.FixedAmountDecimals.
+func summaryResponse(res *models.PaymentsSummaryResponse, cfg Config) any {
+	if cfg.FixedAmountDecimals <= 0 {
+		return res
+	}
+
+	fixed := fixedAmountResponse{
+		Default:  withFixedAmount(res.Default, cfg.FixedAmountDecimals),
+		Fallback: withFixedAmount(res.Fallback, cfg.FixedAmountDecimals),
+		Outages:  res.Outages,
+	}
+	if res.DryRun != nil {
+		dryRun := withFixedAmount(*res.DryRun, cfg.FixedAmountDecimals)
+		fixed.DryRun = &dryRun
+	}
+	return fixed
+}
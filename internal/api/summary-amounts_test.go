@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+func TestSummaryResponseFixedAmountBytes(t *testing.T) {
+	res := &models.PaymentsSummaryResponse{
+		Default:  models.PaymentsSummary{TotalRequests: 2, TotalAmount: 12.5},
+		Fallback: models.PaymentsSummary{TotalRequests: 1, TotalAmount: 3},
+	}
+
+	tests := []struct {
+		name     string
+		decimals int
+		want     string
+	}{
+		{
+			name:     "numbers",
+			decimals: 0,
+			want:     `{"default":{"totalRequests":2,"totalAmount":12.5},"fallback":{"totalRequests":1,"totalAmount":3}}`,
+		},
+		{
+			name:     "two decimals",
+			decimals: 2,
+			want:     `{"default":{"totalRequests":2,"totalAmount":"12.50"},"fallback":{"totalRequests":1,"totalAmount":"3.00"}}`,
+		},
+		{
+			name:     "three decimals",
+			decimals: 3,
+			want:     `{"default":{"totalRequests":2,"totalAmount":"12.500"},"fallback":{"totalRequests":1,"totalAmount":"3.000"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(summaryResponse(res, Config{FixedAmountDecimals: tt.decimals}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("summary = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentsSummaryHandlerFixedAmount(t *testing.T) {
+	target := "/payments-summary?from=" + testRequestedAt.Add(-time.Hour).Format(time.RFC3339) +
+		"&to=" + testRequestedAt.Add(time.Hour).Format(time.RFC3339)
+	rec := httptest.NewRecorder()
+	paymentsSummaryHandler(newTestProcessor(t), queue.New(10), Config{FixedAmountDecimals: 2}).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	want := `{"default":{"totalRequests":1,"totalAmount":"19.90"},"fallback":{"totalRequests":0,"totalAmount":"0.00"}}` + "\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body, want)
+	}
+}
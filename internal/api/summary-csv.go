@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/csv"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeCSV  = "text/csv"
+)
+
+// negotiateSummaryType picks the summary format from an Accept header, by
+// q-value and then by order. It reports false when neither JSON nor CSV is
+// acceptable. No Accept header at all means JSON.
+func negotiateSummaryType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return contentTypeJSON, true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var offered string
+		switch mediaType {
+		case contentTypeJSON, "application/*", "*/*":
+			offered = contentTypeJSON
+		case contentTypeCSV, "text/*":
+			offered = contentTypeCSV
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = offered, q
+		}
+	}
+	return best, best != ""
+}
+
+// writeSummaryCSV writes one row per processor. The latency columns are only
+// there when latency was asked for. Amounts get decimals places when set.
+func writeSummaryCSV(w http.ResponseWriter, res *models.PaymentsSummaryResponse, opts paymentProcessor.SummaryOptions, decimals int) {
+	w.Header().Set("Content-Type", contentTypeCSV+"; charset=utf-8")
+	cw := csv.NewWriter(w)
+
+	header := []string{"processor", "totalRequests", "totalAmount"}
+	if opts.Latency {
+		header = append(header, "p50", "p99")
+	}
+	cw.Write(header)
+
+	rows := []struct {
+		processor string
+		summary   *models.PaymentsSummary
+	}{
+		{"default", &res.Default},
+		{"fallback", &res.Fallback},
+		{"dryRun", res.DryRun},
+	}
+	for _, row := range rows {
+		s := row.summary
+		if s == nil || (opts.OmitEmpty && s.TotalRequests == 0) {
+			continue
+		}
+
+		record := []string{
+			row.processor,
+			strconv.Itoa(s.TotalRequests),
+			formatAmount(s.TotalAmount, decimals),
+		}
+		if opts.Latency {
+			p50, p99 := "", ""
+			if s.Latency != nil {
+				p50 = strconv.FormatFloat(s.Latency.P50, 'f', -1, 64)
+				p99 = strconv.FormatFloat(s.Latency.P99, 'f', -1, 64)
+			}
+			record = append(record, p50, p99)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+const (
+	defaultTimeseriesBucket = time.Minute
+	minTimeseriesBucket     = time.Second
+	// maxTimeseriesBuckets bounds from, to and bucket together so a tiny
+	// bucket over a long run can't build a huge response
+	maxTimeseriesBuckets = 10000
+)
+
+type summaryTimeseriesResponse struct {
+	Bucket  string                 `json:"bucket"`
+	Buckets []models.SummaryBucket `json:"buckets"`
+}
+
+func paymentsSummaryTimeseriesHandler(p *paymentProcessor.PaymentProcessor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		q := r.URL.Query()
+		if !q.Has("from") || !q.Has("to") {
+			writeError(w, http.StatusBadRequest, "invalid_range", "from and to are required")
+			return
+		}
+		from := parseRequestedAt(q.Get("from")).UTC().UnixMilli()
+		to := parseRequestedAt(q.Get("to")).UTC().UnixMilli()
+		if from > to {
+			writeError(w, http.StatusBadRequest, "invalid_range", "from must not be after to")
+			return
+		}
+
+		bucket := defaultTimeseriesBucket
+		if q.Has("bucket") {
+			var err error
+			bucket, err = time.ParseDuration(q.Get("bucket"))
+			if err != nil || bucket < minTimeseriesBucket {
+				writeError(w, http.StatusBadRequest, "invalid_bucket", fmt.Sprintf("bucket must be a duration of at least %s", minTimeseriesBucket))
+				return
+			}
+		}
+		if (to-from)/bucket.Milliseconds() >= maxTimeseriesBuckets {
+			writeError(w, http.StatusBadRequest, "too_many_buckets", fmt.Sprintf("range spans more than %d buckets", maxTimeseriesBuckets))
+			return
+		}
+
+		buckets, err := p.SummaryTimeSeries(r.Context(), from, to, bucket)
+		if errors.Is(err, paymentProcessor.ErrSummaryBusy) {
+			writeError(w, http.StatusTooManyRequests, "summary_busy", "Too many summaries in progress")
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			writeError(w, http.StatusInternalServerError, "summary_failed", "failed to get payments summary")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summaryTimeseriesResponse{
+			Bucket:  bucket.String(),
+			Buckets: buckets,
+		})
+	}
+}
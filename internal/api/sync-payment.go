@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// processPaymentNow processes a payment within the request instead of queueing
+// it, so a 201 means it is recorded.
+func processPaymentNow(w http.ResponseWriter, r *http.Request, p *paymentProcessor.PaymentProcessor, body []byte) {
+	task := tasks.ProcessPaymentTask{}
+	if err := json.Unmarshal(body, &task); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_payload", "invalid payment payload")
+		return
+	}
+	task.StartedAt = time.Now()
+
+	if task.ForcedProcessor == "" {
+		if err := p.WaitUp(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "processor_unavailable", "Payment processor is down")
+			return
+		}
+	}
+
+	// once sent, the processor may charge it, so see it through even if the
+	// client hangs up
+	if _, err := p.ProcessTask(context.WithoutCancel(r.Context()), task); err != nil {
+		fmt.Printf("failed to process payment %s: %v\n", task.CorrelationId, err)
+		if reason := paymentProcessor.UnconfirmedReason(err); reason != "" {
+			// it may have been charged, so don't tell the client it was rejected
+			if err := p.DeadLetter(context.WithoutCancel(r.Context()), task, reason, 1); err != nil {
+				fmt.Println("failed to dead letter task:", err)
+			}
+			writeError(w, http.StatusBadGateway, "payment_unconfirmed", "Payment may have been processed but could not be confirmed")
+			return
+		}
+		if errors.Is(err, paymentProcessor.ErrPermanent) {
+			writeError(w, http.StatusUnprocessableEntity, "payment_rejected", "Payment was not processed")
+			return
+		}
+		writeError(w, http.StatusServiceUnavailable, "processor_unavailable", "Payment processor is unavailable")
+		return
+	}
+
+	w.Header().Set("Location", "/payments/"+url.PathEscape(task.CorrelationId))
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// failSaves is a client hook failing every payment save script, as if Redis
+// went away right after the processor took the payment.
+type failSaves struct{}
+
+func (failSaves) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (failSaves) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if name := cmd.Name(); name == "evalsha" || name == "eval" {
+			err := errors.New("LOADING Redis is loading the dataset in memory")
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (failSaves) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestSyncPaymentNotRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("PROCESSOR_DEFAULT_URL", server.URL)
+	t.Setenv("PROCESSOR_FALLBACK_URL", server.URL)
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	p := paymentProcessor.NewPaymentProcessor(context.Background(), client)
+	p.SetUp(true)
+	client.AddHook(failSaves{})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(testPayment))
+	paymentHandler(p, queue.New(10), &atomic.Bool{}, Config{SyncPayments: true, AcceptedStatus: http.StatusAccepted}).
+		ServeHTTP(rec, req)
+
+	// a 422 would invite the client to pay again for a charged payment
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+	if !strings.Contains(rec.Body.String(), "payment_unconfirmed") {
+		t.Errorf("body = %s, want a payment_unconfirmed error", rec.Body)
+	}
+
+	entries, total, err := p.DeadLetters(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || entries[0].Reason != "processed but not recorded" {
+		t.Errorf("%d dead letters %+v, want the payment parked as not recorded", total, entries)
+	}
+}
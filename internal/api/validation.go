@@ -0,0 +1,53 @@
+package api
+
+import (
+	"strings"
+
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// normalizeCorrelationID trims the correlationId, lowercasing it too when
+// asked, and only re-encodes the body when that changed anything.
+func normalizeCorrelationID(body []byte, lower bool) ([]byte, error) {
+	task := tasks.ProcessPaymentTask{}
+	if err := json.Unmarshal(body, &task); err != nil {
+		return nil, err
+	}
+
+	normalized := strings.TrimSpace(task.CorrelationId)
+	if lower {
+		normalized = strings.ToLower(normalized)
+	}
+	if normalized == task.CorrelationId {
+		return body, nil
+	}
+
+	task.CorrelationId = normalized
+	return json.Marshal(task)
+}
+
+// isUUID reports whether s is a canonical 8-4-4-4-12 hex UUID.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !isHex(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
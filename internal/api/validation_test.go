@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+func TestCorrelationIDNormalization(t *testing.T) {
+	tests := []struct {
+		normalization string
+		want          []string
+	}{
+		{normalization: "none", want: []string{" ABC ", "abc"}},
+		{normalization: "trim", want: []string{"ABC", "abc"}},
+		{normalization: "lower", want: []string{"abc", "abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.normalization, func(t *testing.T) {
+			q := queue.New(10)
+			cfg := Config{CorrelationIDNormalization: tt.normalization}
+			for _, id := range []string{" ABC ", "abc"} {
+				rec := postPayment(t, cfg, q, `{"correlationId":"`+id+`","amount":19.9}`, nil)
+				if rec.Code != http.StatusAccepted {
+					t.Fatalf("correlationId %q: status = %d", id, rec.Code)
+				}
+			}
+
+			for i, want := range tt.want {
+				item, ok := q.Pop()
+				if !ok {
+					t.Fatalf("payment %d was not queued", i)
+				}
+				task := tasks.ProcessPaymentTask{}
+				if err := json.Unmarshal(item.Data, &task); err != nil {
+					t.Fatal(err)
+				}
+				if task.CorrelationId != want {
+					t.Errorf("payment %d queued with correlationId %q, want %q", i, task.CorrelationId, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "lowercase", id: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", want: true},
+		{name: "uppercase", id: "4A7901B8-7D26-4D9D-AA19-4DC1C7CF60B3", want: true},
+		{name: "mixed case", id: "4a7901B8-7d26-4D9d-aa19-4dc1c7CF60b3", want: true},
+		{name: "empty", id: "", want: false},
+		{name: "one short", id: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b", want: false},
+		{name: "one long", id: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b30", want: false},
+		{name: "no hyphens", id: "4a7901b87d264d9daa194dc1c7cf60b3", want: false},
+		{name: "hyphen moved", id: "4a7901b-87d26-4d9d-aa19-4dc1c7cf60b3", want: false},
+		{name: "hyphen replaced", id: "4a7901b8_7d26-4d9d-aa19-4dc1c7cf60b3", want: false},
+		{name: "hyphen in a group", id: "4a7901b8-7d26-4d9d-aa19-4dc1c7c-60b3", want: false},
+		{name: "non hex letter", id: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60bg", want: false},
+		{name: "braces", id: "{4a7901b8-7d26-4d9d-aa19-4dc1c7cf60}", want: false},
+		{name: "padded", id: " 4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b", want: false},
+		{name: "multibyte", id: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60é", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUUID(tt.id); got != tt.want {
+				t.Errorf("isUUID(%q) = %t, want %t", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentHandlerValidatesCorrelationID(t *testing.T) {
+	tests := []struct {
+		name       string
+		validate   bool
+		id         string
+		wantStatus int
+	}{
+		{name: "uuid", validate: true, id: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", wantStatus: http.StatusAccepted},
+		{name: "not a uuid", validate: true, id: "payment-1", wantStatus: http.StatusBadRequest},
+		{name: "not validated", validate: false, id: "payment-1", wantStatus: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := queue.New(10)
+			rec := postPayment(t, Config{ValidateCorrelationID: tt.validate}, q, `{"correlationId":"`+tt.id+`","amount":19.9}`, nil)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusBadRequest {
+				if !strings.Contains(rec.Body.String(), "invalid_correlation_id") {
+					t.Errorf("answered %s, want an invalid_correlation_id error", rec.Body)
+				}
+				if q.Len() != 0 {
+					t.Errorf("%d tasks queued, want none", q.Len())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package payment
+
+import (
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+)
+
+type LargePaymentAuditEntry struct {
+	Payment    tasks.ProcessPaymentPayload `json:"payment"`
+	ReceivedAt string                      `json:"receivedAt"`
+}
@@ -0,0 +1,12 @@
+package payment
+
+import (
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+)
+
+type DeadLetterEntry struct {
+	Payment  tasks.ProcessPaymentTask `json:"payment"`
+	Reason   string                   `json:"reason"`
+	Attempts int                      `json:"attempts"`
+	FailedAt string                   `json:"failedAt"`
+}
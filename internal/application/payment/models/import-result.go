@@ -0,0 +1,18 @@
+package payment
+
+// ImportResult is how an import went, line by line. Valid lines are imported
+// even when others are rejected.
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+	// Errors says why lines were skipped, the first ones only if there are
+	// many of them.
+	Errors []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is why one line of an import was skipped.
+type ImportError struct {
+	// Line is the 1-based line number in the NDJSON body.
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,9 @@
+package payment
+
+// OutageWindow is a stretch of time the health checks had the default
+// processor down, during which payments may have been dead-lettered. To is
+// empty while it still is down.
+type OutageWindow struct {
+	From string `json:"from"`
+	To   string `json:"to,omitempty"`
+}
@@ -0,0 +1,10 @@
+package payment
+
+// PaymentEvent is published for every saved payment while events are enabled.
+type PaymentEvent struct {
+	CorrelationId string  `json:"correlationId"`
+	Amount        float64 `json:"amount"`
+	Processor     string  `json:"processor"`
+	Tier          int     `json:"tier"`
+	RequestedAt   string  `json:"requestedAt"`
+}
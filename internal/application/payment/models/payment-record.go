@@ -0,0 +1,35 @@
+package payment
+
+import (
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+)
+
+// PaymentRecordVersion must be bumped whenever the stored shape changes, so
+// older records are skipped instead of being summed with the wrong meaning.
+const PaymentRecordVersion = 1
+
+type PaymentRecord struct {
+	Version int `json:"version"`
+	tasks.ProcessPaymentTask
+	// DurationMs is how long the processor took to answer, zero on older records.
+	DurationMs float64 `json:"durationMs,omitempty"`
+	// DryRun marks payments saved without ever reaching a processor.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Tier is the processor chain position that took the payment: 0 is the
+	// default, 1 the fallback and above that the extra fallbacks, which the
+	// summary counts as fallback.
+	Tier int `json:"tier,omitempty"`
+	// Timing is only stored when pipeline timing is enabled.
+	Timing *PaymentTiming `json:"timing,omitempty"`
+	// ProcessedBy is the instance that processed the payment, only stored
+	// when instance tagging is enabled.
+	ProcessedBy string `json:"processedBy,omitempty"`
+}
+
+// PaymentTiming holds RFC 3339 timestamps of a payment going through the
+// pipeline. EnqueuedAt is empty for tasks that came from the Redis queue.
+type PaymentTiming struct {
+	EnqueuedAt  string `json:"enqueuedAt,omitempty"`
+	StartedAt   string `json:"startedAt"`
+	CompletedAt string `json:"completedAt"`
+}
@@ -0,0 +1,8 @@
+package payment
+
+type ReconcileResult struct {
+	Local  PaymentsSummaryResponse `json:"local"`
+	Remote PaymentsSummaryResponse `json:"remote"`
+	// Delta is local minus remote, per processor.
+	Delta PaymentsSummaryResponse `json:"delta"`
+}
@@ -0,0 +1,18 @@
+package payment
+
+type RedisPoolStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"totalConns"`
+	IdleConns  uint32 `json:"idleConns"`
+	StaleConns uint32 `json:"staleConns"`
+}
+
+type RedisHealth struct {
+	// Available is the monitor's view, which gates the workers.
+	Available bool           `json:"available"`
+	PingMs    float64        `json:"pingMs"`
+	Error     string         `json:"error,omitempty"`
+	Pool      RedisPoolStats `json:"pool"`
+}
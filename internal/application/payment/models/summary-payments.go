@@ -1,11 +1,38 @@
 package payment
 
 type PaymentsSummary struct {
-	TotalRequests int     `json:"totalRequests"`
-	TotalAmount   float64 `json:"totalAmount"`
+	TotalRequests int             `json:"totalRequests"`
+	TotalAmount   float64         `json:"totalAmount"`
+	Latency       *LatencySummary `json:"latency,omitempty"`
+}
+
+// LatencySummary holds processing duration percentiles, in milliseconds.
+type LatencySummary struct {
+	P50 float64 `json:"p50"`
+	P99 float64 `json:"p99"`
 }
 
 type PaymentsSummaryResponse struct {
 	Default  PaymentsSummary `json:"default"`
 	Fallback PaymentsSummary `json:"fallback"`
+	// DryRun only shows up when dry-run records are in the window.
+	DryRun *PaymentsSummary `json:"dryRun,omitempty"`
+	// Outages only shows up when asked for, and when there were any.
+	Outages []OutageWindow `json:"outages,omitempty"`
+}
+
+// TimingBreakdown splits the time payments spent in the pipeline between
+// waiting in the queue and being processed, in milliseconds.
+type TimingBreakdown struct {
+	Payments   int            `json:"payments"`
+	QueueWait  LatencySummary `json:"queueWait"`
+	Processing LatencySummary `json:"processing"`
+}
+
+// SummaryBucket is one slice of a summary time series. Start is the bucket's
+// first millisecond, as unix millis.
+type SummaryBucket struct {
+	Start    int64           `json:"start"`
+	Default  PaymentsSummary `json:"default"`
+	Fallback PaymentsSummary `json:"fallback"`
 }
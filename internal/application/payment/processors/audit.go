@@ -0,0 +1,34 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// AuditLargePayment records an accepted payment whose amount is unusually
+// high, so it can be reviewed without digging through every record.
+func (p *PaymentProcessor) AuditLargePayment(ctx context.Context, payment tasks.ProcessPaymentPayload) error {
+	entry := models.LargePaymentAuditEntry{
+		Payment:    payment,
+		ReceivedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error on marshalling audit entry: %w", err)
+	}
+
+	if err := p.cache.RPush(ctx, p.getLargePaymentsAuditKey(), j).Err(); err != nil {
+		return fmt.Errorf("error on pushing audit entry: %w", err)
+	}
+	return nil
+}
+
+func (p *PaymentProcessor) getLargePaymentsAuditKey() string {
+	return p.prefixKey("payments:audit:large")
+}
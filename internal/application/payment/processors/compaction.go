@@ -0,0 +1,61 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const compactionChunkSize = 500
+
+// CompactPayments removes every payment scored before the given unix millis,
+// both the record keys and their index entries. It returns how many were removed.
+func (p *PaymentProcessor) CompactPayments(ctx context.Context, before int64) (int, error) {
+	if err := p.requireRedisStore(); err != nil {
+		return 0, err
+	}
+
+	maxScore := fmt.Sprintf("(%d", before)
+	removed := 0
+	for {
+		keys, err := p.cache.ZRangeByScore(ctx, p.getPaymentsIndexKey(), &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   maxScore,
+			Count: compactionChunkSize,
+		}).Result()
+		if err != nil {
+			return removed, fmt.Errorf("error on getting payments to compact: %w", err)
+		}
+		if len(keys) == 0 {
+			return removed, nil
+		}
+
+		members := make([]any, len(keys))
+		for i, k := range keys {
+			members[i] = k
+		}
+
+		var records []any
+		if p.processorIndexes {
+			records, err = p.cache.MGet(ctx, keys...).Result()
+			if err != nil {
+				return removed, fmt.Errorf("error on getting payments to compact: %w", err)
+			}
+		}
+
+		pipe := p.cache.TxPipeline()
+		p.unindexProcessorPayments(ctx, pipe, keys, records)
+		pipe.Del(ctx, keys...)
+		pipe.ZRem(ctx, p.getPaymentsIndexKey(), members...)
+		pipe.Incr(ctx, p.getPaymentsVersionKey())
+		if _, err := pipe.Exec(ctx); err != nil {
+			return removed, fmt.Errorf("error on compacting payments: %w", err)
+		}
+		removed += len(keys)
+
+		if len(keys) < compactionChunkSize {
+			return removed, nil
+		}
+	}
+}
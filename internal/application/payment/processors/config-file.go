@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// processorConfig is the processor setup read from the JSON file CONFIG_FILE
+// points to, so test scenarios can be kept as files. Any of its settings the
+// environment also sets is overridden by the environment.
+type processorConfig struct {
+	DefaultURL        string   `json:"defaultUrl"`
+	FallbackURL       string   `json:"fallbackUrl"`
+	ExtraFallbackURLs []string `json:"extraFallbackUrls"`
+	// the fees are the share of each payment a processor keeps, the extra
+	// ones lining up with ExtraFallbackURLs
+	DefaultFee        float64   `json:"defaultFee"`
+	FallbackFee       float64   `json:"fallbackFee"`
+	ExtraFallbackFees []float64 `json:"extraFallbackFees"`
+	// RequestTimeout bounds each payment POST, as a Go duration like "2s".
+	RequestTimeout string `json:"requestTimeout"`
+}
+
+func readProcessorConfig(path string) (processorConfig, error) {
+	cfg := processorConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, fmt.Errorf("error on opening processor config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("error on decoding processor config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// envOr returns the environment variable when set, fallback otherwise.
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func parseRequestTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("error on parsing processor request timeout: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProcessorChainFromConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scenario.json")
+	config := `{
+		"defaultUrl": "http://file-default",
+		"fallbackUrl": "http://file-fallback",
+		"extraFallbackUrls": ["http://file-fallback2"],
+		"defaultFee": 0.05,
+		"fallbackFee": 0.15,
+		"extraFallbackFees": [0.1],
+		"requestTimeout": "2s"
+	}`
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	for _, key := range []string{
+		"PROCESSOR_DEFAULT_URL", "PROCESSOR_FALLBACK_URL", "PROCESSOR_EXTRA_FALLBACK_URLS",
+		"PROCESSOR_DEFAULT_FEE", "PROCESSOR_FALLBACK_FEE", "PROCESSOR_EXTRA_FALLBACK_FEES",
+		"PROCESSOR_REQUEST_TIMEOUT",
+	} {
+		t.Setenv(key, "")
+	}
+	// the environment wins over the file
+	t.Setenv("PROCESSOR_FALLBACK_URL", "http://env-fallback")
+	t.Setenv("PROCESSOR_DEFAULT_FEE", "0.04")
+
+	chain, err := loadProcessorChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		url string
+		fee float64
+	}{
+		{url: "http://file-default", fee: 0.04},
+		{url: "http://env-fallback", fee: 0.15},
+		{url: "http://file-fallback2", fee: 0.1},
+	}
+	if len(chain.endpoints) != len(want) {
+		t.Fatalf("%d endpoints, want %d", len(chain.endpoints), len(want))
+	}
+	for i, w := range want {
+		if got := chain.endpoints[i]; got.url != w.url || got.fee != w.fee {
+			t.Errorf("tier %d = %s with fee %v, want %s with fee %v", i, got.url, got.fee, w.url, w.fee)
+		}
+	}
+	if chain.requestTimeout.String() != "2s" {
+		t.Errorf("request timeout = %s, want 2s", chain.requestTimeout)
+	}
+}
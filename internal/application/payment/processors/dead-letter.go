@@ -0,0 +1,57 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// DeadLetter parks a task we gave up on, so it can be inspected or replayed later.
+func (p *PaymentProcessor) DeadLetter(ctx context.Context, task tasks.ProcessPaymentTask, reason string, attempts int) error {
+	entry := models.DeadLetterEntry{
+		Payment:  task,
+		Reason:   reason,
+		Attempts: attempts,
+		FailedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error on marshalling dead letter: %w", err)
+	}
+
+	if err := p.cache.RPush(ctx, p.getDeadLetterKey(), j).Err(); err != nil {
+		return fmt.Errorf("error on pushing dead letter: %w", err)
+	}
+	return nil
+}
+
+// DeadLetters returns up to limit parked tasks, oldest first, starting at
+// offset, along with how many are parked in total. Nothing is removed.
+func (p *PaymentProcessor) DeadLetters(ctx context.Context, offset, limit int64) ([]models.DeadLetterEntry, int64, error) {
+	pipe := p.cache.Pipeline()
+	total := pipe.LLen(ctx, p.getDeadLetterKey())
+	raw := pipe.LRange(ctx, p.getDeadLetterKey(), offset, offset+limit-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, fmt.Errorf("error on getting dead letters: %w", err)
+	}
+
+	entries := make([]models.DeadLetterEntry, 0, len(raw.Val()))
+	for _, j := range raw.Val() {
+		entry := models.DeadLetterEntry{}
+		if err := json.Unmarshal([]byte(j), &entry); err != nil {
+			fmt.Println("skipping unreadable dead letter:", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total.Val(), nil
+}
+
+func (p *PaymentProcessor) getDeadLetterKey() string {
+	return p.prefixKey("payments:dead-letter")
+}
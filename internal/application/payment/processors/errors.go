@@ -0,0 +1,39 @@
+package payment
+
+import "errors"
+
+var (
+	// ErrRetryable means the processor failed in a way that may succeed later.
+	ErrRetryable = errors.New("retryable processing error")
+	// ErrPermanent means retrying the payment can't help, or would charge it twice.
+	ErrPermanent = errors.New("permanent processing error")
+	// ErrUnexpectedSuccess means the processor answered a 2xx that is not one
+	// of the success statuses, so it may have taken a payment we did not save.
+	// It always comes wrapped with ErrPermanent.
+	ErrUnexpectedSuccess = errors.New("unexpected success status")
+	// ErrNotRecorded means the processor took the payment but saving it failed
+	// for good, so it was charged without being recorded. It always comes
+	// wrapped with ErrPermanent.
+	ErrNotRecorded = errors.New("processed payment not recorded")
+	// ErrProcessorDown means the processor could not be reached at all.
+	ErrProcessorDown = errors.New("payment processor is down")
+	// ErrPaymentNotFound means no processed payment is stored under that id.
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrSummaryBusy means too many summaries were already being computed.
+	ErrSummaryBusy = errors.New("too many concurrent summaries")
+	// ErrUnsupportedStore means the operation only works with payments saved
+	// to Redis, not with the file store.
+	ErrUnsupportedStore = errors.New("not supported by the payment store")
+)
+
+// UnconfirmedReason is why a payment the processor may have charged, without
+// us recording it, is dead-lettered for reconciling, or "" for any other error.
+func UnconfirmedReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnexpectedSuccess):
+		return "unexpected success status"
+	case errors.Is(err, ErrNotRecorded):
+		return "processed but not recorded"
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// paymentEventsBuffer is how many events a subscriber may fall behind by
+// before new ones are dropped for it.
+const paymentEventsBuffer = 256
+
+// EnablePaymentEvents publishes every saved payment on a Redis channel, which
+// costs one extra round trip per payment.
+func (p *PaymentProcessor) EnablePaymentEvents() {
+	p.paymentEvents = true
+}
+
+func (p *PaymentProcessor) PaymentEventsEnabled() bool {
+	return p.paymentEvents
+}
+
+func (p *PaymentProcessor) publishPaymentEvent(ctx context.Context, record models.PaymentRecord) {
+	if !p.paymentEvents {
+		return
+	}
+
+	processor := processorName(record.OnDefault)
+	if record.DryRun {
+		processor = "dry-run"
+	}
+	j, err := json.Marshal(models.PaymentEvent{
+		CorrelationId: record.CorrelationId,
+		Amount:        record.Amount,
+		Processor:     processor,
+		Tier:          record.Tier,
+		RequestedAt:   record.RequestedAt,
+	})
+	if err != nil {
+		fmt.Println("failed to marshal payment event:", err)
+		return
+	}
+
+	if err := p.cache.Publish(ctx, p.getPaymentEventsChannel(), j).Err(); err != nil {
+		fmt.Println("failed to publish payment event:", err)
+	}
+}
+
+// SubscribePaymentEvents streams the payment events published by any
+// instance until ctx is done. A subscriber that can't keep up loses events
+// instead of holding back the others.
+func (p *PaymentProcessor) SubscribePaymentEvents(ctx context.Context) <-chan []byte {
+	events := make(chan []byte, paymentEventsBuffer)
+	sub := p.cache.Subscribe(ctx, p.getPaymentEventsChannel())
+
+	go func() {
+		defer close(events)
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case events <- []byte(msg.Payload):
+				default:
+				}
+			}
+		}
+	}()
+	return events
+}
+
+func (p *PaymentProcessor) getPaymentEventsChannel() string {
+	return p.prefixKey("payments:events")
+}
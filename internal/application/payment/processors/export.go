@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const exportChunkSize = 500
+
+// ExportPayments walks the payments index in score order and hands each stored
+// record to fn, fetching at most exportChunkSize records at a time.
+func (p *PaymentProcessor) ExportPayments(ctx context.Context, from, to string, fn func(record []byte) error) error {
+	if err := p.requireRedisStore(); err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		keys, err := p.cache.ZRangeByScore(ctx, p.getPaymentsIndexKey(), &redis.ZRangeBy{
+			Min:    from,
+			Max:    to,
+			Offset: offset,
+			Count:  exportChunkSize,
+		}).Result()
+		if err != nil {
+			fmt.Println(err)
+			return fmt.Errorf("failed to get payments to export")
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		results, err := p.cache.MGet(ctx, keys...).Result()
+		if err != nil {
+			fmt.Println(err)
+			return fmt.Errorf("failed to get payments")
+		}
+
+		for _, result := range results {
+			if result == nil {
+				continue
+			}
+			record, err := p.recordJSON([]byte(result.(string)))
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < exportChunkSize {
+			return nil
+		}
+		offset += int64(len(keys))
+	}
+}
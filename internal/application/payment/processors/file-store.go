@@ -0,0 +1,138 @@
+package payment
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+type fileRecord struct {
+	At int64 `json:"at"`
+	models.PaymentRecord
+}
+
+// fileStore appends payments as JSON lines to a local file and answers
+// summaries by scanning it. It is only correct for single-instance runs,
+// since other instances never see the file.
+type fileStore struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	count  int64
+	mu     sync.Mutex
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error on opening payments file: %w", err)
+	}
+
+	s := &fileStore{
+		path:   path,
+		file:   f,
+		writer: bufio.NewWriterSize(f, 64*1024),
+	}
+
+	// pick up what a previous run left behind so the version keeps moving up
+	err = s.scan(context.Background(), func(r fileRecord) {
+		s.count++
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// requireRedisStore fails with ErrUnsupportedStore when payments are saved
+// somewhere else than Redis, for the operations reading or writing the
+// records there directly.
+func (p *PaymentProcessor) requireRedisStore() error {
+	if _, onRedis := p.store.(*redisStore); !onRedis {
+		return ErrUnsupportedStore
+	}
+	return nil
+}
+
+func (s *fileStore) Save(ctx context.Context, at int64, record models.PaymentRecord) error {
+	j, err := json.Marshal(fileRecord{
+		At:            at,
+		PaymentRecord: record,
+	})
+	if err != nil {
+		return fmt.Errorf("error on marshalling processed payment: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.writer.Write(append(j, '\n')); err != nil {
+		return fmt.Errorf("error on appending processed payment: %w", err)
+	}
+	s.count++
+	return nil
+}
+
+func (s *fileStore) Range(ctx context.Context, from, to int64, fn func(at int64, record models.PaymentRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("error on flushing payments file: %w", err)
+	}
+	return s.scan(ctx, func(r fileRecord) {
+		if r.At >= from && r.At <= to {
+			fn(r.At, r.PaymentRecord)
+		}
+	})
+}
+
+func (s *fileStore) scan(ctx context.Context, fn func(r fileRecord)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("error on opening payments file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 0; scanner.Scan(); line++ {
+		if line%rangeChunkSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		r := fileRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		fn(r)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error on reading payments file: %w", err)
+	}
+	return nil
+}
+
+// Version is the count of saves, every one appends a line.
+func (s *fileStore) Version(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count, nil
+}
+
+func (s *fileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("error on flushing payments file: %w", err)
+	}
+	return s.file.Close()
+}
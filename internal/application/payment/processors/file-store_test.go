@@ -0,0 +1,78 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStoreRejectsRedisOnlyOperations(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	if err := p.UseFileStore(filepath.Join(t.TempDir(), "payments.ndjson")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	ctx := context.Background()
+
+	err := p.ExportPayments(ctx, "-inf", "+inf", func([]byte) error { return nil })
+	if !errors.Is(err, ErrUnsupportedStore) {
+		t.Errorf("ExportPayments error = %v, want ErrUnsupportedStore", err)
+	}
+	if _, err := p.GetPayment(ctx, "any"); !errors.Is(err, ErrUnsupportedStore) {
+		t.Errorf("GetPayment error = %v, want ErrUnsupportedStore", err)
+	}
+	if _, err := p.ImportPayments(ctx, strings.NewReader("")); !errors.Is(err, ErrUnsupportedStore) {
+		t.Errorf("ImportPayments error = %v, want ErrUnsupportedStore", err)
+	}
+	if _, err := p.CompactPayments(ctx, testEpoch); !errors.Is(err, ErrUnsupportedStore) {
+		t.Errorf("CompactPayments error = %v, want ErrUnsupportedStore", err)
+	}
+}
+
+func benchmarkStores(b *testing.B, fn func(b *testing.B, p *PaymentProcessor)) {
+	b.Run("redis", func(b *testing.B) {
+		p, _ := newTestProcessor(b)
+		fn(b, p)
+	})
+	b.Run("file", func(b *testing.B) {
+		p, _ := newTestProcessor(b)
+		if err := p.UseFileStore(filepath.Join(b.TempDir(), "payments.ndjson")); err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(p.Close)
+		fn(b, p)
+	})
+}
+
+func BenchmarkStoreSave(b *testing.B) {
+	benchmarkStores(b, func(b *testing.B, p *PaymentProcessor) {
+		ctx := context.Background()
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			if err := p.store.Save(ctx, testEpoch+int64(i), testRecord(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStoreSummary(b *testing.B) {
+	benchmarkStores(b, func(b *testing.B, p *PaymentProcessor) {
+		const payments = 10_000
+		saveTestRecords(b, p, payments)
+
+		ctx := context.Background()
+		b.ReportAllocs()
+		for b.Loop() {
+			res, err := p.summaryPayments(ctx, testEpoch, testEpoch+payments, SummaryOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if got := res.Default.TotalRequests + res.Fallback.TotalRequests; got != payments {
+				b.Fatalf("summarized %d payments, want %d", got, payments)
+			}
+		}
+	})
+}
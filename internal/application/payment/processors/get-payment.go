@@ -0,0 +1,26 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GetPayment returns the stored record of a processed payment as JSON, or
+// ErrPaymentNotFound.
+func (p *PaymentProcessor) GetPayment(ctx context.Context, correlationId string) ([]byte, error) {
+	if err := p.requireRedisStore(); err != nil {
+		return nil, err
+	}
+
+	record, err := p.cache.Get(ctx, p.getPaymentKey(correlationId)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error on getting payment: %w", err)
+	}
+	return p.recordJSON(record)
+}
@@ -2,11 +2,22 @@ package payment
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/json"
+	"github.com/redis/go-redis/v9"
 )
 
 const HEALTH_CHECK_KEY = "health_check"
+const HEALTH_CHECK_SUCCESSES_KEY = "health_check:successes"
+const HEALTH_CHECK_FALLBACK_KEY = "health_check:fallback"
+
+// errProbeUnreachable means the health endpoint could not be reached at all.
+var errProbeUnreachable = errors.New("health probe could not reach the processor")
 
 type HealthCheckResponse struct {
 	Failing         bool `json:"failing"`
@@ -15,27 +26,172 @@ type HealthCheckResponse struct {
 
 func (p *PaymentProcessor) HealthCheck(ctx context.Context, masterInstance bool) {
 	if masterInstance {
-		resp, err := p.client.Get(p.baseURL() + "/payments/service-health")
+		healthCheckRes, err := p.probe(ctx, p.baseURL())
 		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		defer resp.Body.Close()
-
-		healthCheckRes := HealthCheckResponse{}
-		if err := json.NewDecoder(resp.Body).Decode(&healthCheckRes); err != nil {
-			fmt.Println(err)
+			p.probeFailed(ctx, err)
 			return
 		}
 
 		fmt.Println("hc res", healthCheckRes)
-		p.cache.Set(ctx, HEALTH_CHECK_KEY, !healthCheckRes.Failing, 0)
-		p.SetUp(!healthCheckRes.Failing)
+		p.unreachableSince = time.Time{}
+		p.applyHealth(ctx, healthCheckRes)
 		return
 	}
 
-	upCached := p.cache.Get(ctx, HEALTH_CHECK_KEY)
-	up, _ := upCached.Bool()
+	upCached := p.cache.Get(ctx, p.getHealthCheckKey(HEALTH_CHECK_KEY))
+	up, err := upCached.Bool()
+	if err == redis.Nil {
+		// the master hasn't probed yet
+		up = p.coldStartUp
+	}
 	fmt.Println("hc res", up)
+	p.setUpFromHealthCheck(up)
+}
+
+// HealthCheckProcessor probes one processor regardless of which one is in
+// use, for masters probing both on their own schedule. The default result
+// drives routing like HealthCheck does, the fallback one is only recorded.
+func (p *PaymentProcessor) HealthCheckProcessor(ctx context.Context, onDefault bool) {
+	healthCheckRes, err := p.probe(ctx, p.processorURL(onDefault))
+	if err != nil && onDefault {
+		p.probeFailed(ctx, err)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		if errors.Is(err, errProbeUnreachable) {
+			p.cache.Set(ctx, p.getHealthCheckKey(HEALTH_CHECK_FALLBACK_KEY), false, 0)
+		}
+		return
+	}
+
+	fmt.Println("hc res", processorName(onDefault), healthCheckRes)
+	if !onDefault {
+		p.cache.Set(ctx, p.getHealthCheckKey(HEALTH_CHECK_FALLBACK_KEY), !healthCheckRes.Failing, 0)
+		return
+	}
+	p.unreachableSince = time.Time{}
+	p.applyHealth(ctx, healthCheckRes)
+}
+
+func (p *PaymentProcessor) probe(ctx context.Context, baseURL string) (HealthCheckResponse, error) {
+	healthCheckRes := HealthCheckResponse{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/payments/service-health", nil)
+	if err != nil {
+		return healthCheckRes, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return healthCheckRes, fmt.Errorf("%w: %w", errProbeUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&healthCheckRes); err != nil {
+		return healthCheckRes, err
+	}
+	return healthCheckRes, nil
+}
+
+// probeFailed marks the processor down once it has been unreachable for the
+// whole grace period. Any other probe error, like an unreadable answer from a
+// rate-limited health endpoint, says nothing about its health and is ignored.
+func (p *PaymentProcessor) probeFailed(ctx context.Context, err error) {
+	fmt.Println(err)
+	if !errors.Is(err, errProbeUnreachable) {
+		return
+	}
+
+	if p.unreachableSince.IsZero() {
+		p.unreachableSince = time.Now()
+	}
+	if time.Since(p.unreachableSince) >= p.unreachableGrace {
+		p.applyHealth(ctx, HealthCheckResponse{Failing: true})
+	}
+}
+
+// SetUnreachableGrace is how long health probes may fail to reach the
+// processor before it is marked down. Zero marks it down on the first one.
+func (p *PaymentProcessor) SetUnreachableGrace(grace time.Duration) {
+	p.unreachableGrace = grace
+}
+
+func (p *PaymentProcessor) applyHealth(ctx context.Context, healthCheckRes HealthCheckResponse) {
+	up := p.stableHealth(ctx, !healthCheckRes.Failing)
+	previous, err := p.cache.SetArgs(ctx, p.getHealthCheckKey(HEALTH_CHECK_KEY), up, redis.SetArgs{Get: true}).Result()
+	switch {
+	case err == redis.Nil:
+		// nothing recorded yet, only a down is a transition worth keeping
+		if !up {
+			p.recordHealthTransition(ctx, up)
+		}
+	case err != nil:
+		fmt.Println("failed to save health check:", err)
+	default:
+		if wasUp, _ := strconv.ParseBool(previous); wasUp != up {
+			p.recordHealthTransition(ctx, up)
+		}
+	}
+	p.setUpFromHealthCheck(up)
+}
+
+// SetHealthRecoveryProbes makes the processor count as up again only after
+// probes consecutive healthy checks, while a single failing one still takes
+// it down right away. Values below 1 are treated as 1.
+func (p *PaymentProcessor) SetHealthRecoveryProbes(probes int) {
+	p.recoveryProbes = max(probes, 1)
+}
+
+// stableHealth applies the recovery hysteresis to a probe result. The
+// consecutive successes live in the cache so a new master picks up the count.
+func (p *PaymentProcessor) stableHealth(ctx context.Context, healthy bool) bool {
+	if !healthy {
+		if err := p.cache.Set(ctx, p.getHealthCheckKey(HEALTH_CHECK_SUCCESSES_KEY), 0, 0).Err(); err != nil {
+			fmt.Println("failed to reset health check successes:", err)
+		}
+		return false
+	}
+	if p.recoveryProbes <= 1 {
+		return true
+	}
+
+	successes, err := p.cache.Incr(ctx, p.getHealthCheckKey(HEALTH_CHECK_SUCCESSES_KEY)).Result()
+	if err != nil {
+		fmt.Println("failed to count health check successes:", err)
+		return p.IsUp()
+	}
+	return p.IsUp() || successes >= int64(p.recoveryProbes)
+}
+
+// SetHealthCheckPair keeps this processor pair's health state under its own
+// keys, so deployments of different pairs can share a Redis. The up flag is
+// reloaded from the pair's key. An empty pair keeps the shared keys.
+func (p *PaymentProcessor) SetHealthCheckPair(ctx context.Context, pair string) {
+	p.healthCheckPair = pair
+	if pair == "" {
+		return
+	}
+
+	up, _ := p.cache.Get(ctx, p.getHealthCheckKey(HEALTH_CHECK_KEY)).Bool()
+	fmt.Printf("health check pair %s, initializing up with %t\n", pair, up)
 	p.SetUp(up)
 }
+
+// SetColdStartUp decides where payments go while no health check was ever
+// recorded, like on a fresh deployment. Up routes to the cheaper default right
+// away, at the risk of failing and retrying the first payments if it happens
+// to be down; down plays safe on the fallback and pays its fee until the first
+// probe. Call it after SetHealthCheckPair.
+func (p *PaymentProcessor) SetColdStartUp(ctx context.Context, up bool) {
+	p.coldStartUp = up
+	if err := p.cache.Get(ctx, p.getHealthCheckKey(HEALTH_CHECK_KEY)).Err(); err == redis.Nil {
+		fmt.Printf("no health check recorded yet, initializing up with %t\n", up)
+		p.SetUp(up)
+	}
+}
+
+func (p *PaymentProcessor) getHealthCheckKey(key string) string {
+	if p.healthCheckPair == "" {
+		return p.prefixKey(key)
+	}
+	return p.prefixKey(p.healthCheckPair + ":" + key)
+}
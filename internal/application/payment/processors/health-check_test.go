@@ -0,0 +1,95 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// reportHealth is a health endpoint answering with body.
+func reportHealth(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+func TestHealthCheckOutcomes(t *testing.T) {
+	tests := []struct {
+		name string
+		// handler is nil for a processor refusing connections
+		handler http.HandlerFunc
+		wasUp   bool
+		wantUp  bool
+	}{
+		{name: "network error", wasUp: true, wantUp: false},
+		{name: "failing true", handler: reportHealth(`{"failing":true,"minResponseTime":0}`), wasUp: true, wantUp: false},
+		{name: "failing false", handler: reportHealth(`{"failing":false,"minResponseTime":0}`), wasUp: false, wantUp: true},
+		{name: "unreadable answer", handler: respondWith(http.StatusTooManyRequests), wasUp: true, wantUp: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p *PaymentProcessor
+			if tt.handler == nil {
+				t.Setenv("PROCESSOR_DEFAULT_URL", closedURL(t))
+				t.Setenv("PROCESSOR_FALLBACK_URL", closedURL(t))
+				p, _ = newTestProcessor(t)
+			} else {
+				p, _ = newTestProcessorServing(t, tt.handler)
+			}
+			p.SetUp(tt.wasUp)
+
+			p.HealthCheck(context.Background(), true)
+
+			if p.IsUp() != tt.wantUp {
+				t.Errorf("up = %t, want %t", p.IsUp(), tt.wantUp)
+			}
+		})
+	}
+}
+
+func TestHealthCheckUnreachableGrace(t *testing.T) {
+	t.Setenv("PROCESSOR_DEFAULT_URL", closedURL(t))
+	t.Setenv("PROCESSOR_FALLBACK_URL", closedURL(t))
+	p, _ := newTestProcessor(t)
+	p.SetUnreachableGrace(20 * time.Millisecond)
+	p.SetUp(true)
+	ctx := context.Background()
+
+	p.HealthCheck(ctx, true)
+	if !p.IsUp() {
+		t.Fatal("marked down on the first unreachable probe")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	p.HealthCheck(ctx, true)
+	if p.IsUp() {
+		t.Error("still up once unreachable for the whole grace")
+	}
+}
+
+func TestHealthCheckPairsDontInterfere(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	failing := newTestProcessorServingOn(t, mr, reportHealth(`{"failing":true,"minResponseTime":0}`))
+	failing.SetHealthCheckPair(ctx, "a")
+	healthy := newTestProcessorServingOn(t, mr, reportHealth(`{"failing":false,"minResponseTime":0}`))
+	healthy.SetHealthCheckPair(ctx, "b")
+
+	failing.SetUp(true)
+	healthy.SetUp(false)
+	failing.HealthCheck(ctx, true)
+	healthy.HealthCheck(ctx, true)
+
+	for pair, wantUp := range map[string]bool{"a": false, "b": true} {
+		replica := newTestProcessorOn(t, mr)
+		replica.SetHealthCheckPair(ctx, pair)
+		replica.HealthCheck(ctx, false)
+		if replica.IsUp() != wantUp {
+			t.Errorf("pair %s: replica up = %t, want %t", pair, replica.IsUp(), wantUp)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestProcessor returns a processor backed by an in-memory Redis that is
+// torn down with the test.
+func newTestProcessor(tb testing.TB) (*PaymentProcessor, *miniredis.Miniredis) {
+	tb.Helper()
+	mr := miniredis.RunT(tb)
+	return newTestProcessorOn(tb, mr), mr
+}
+
+// newTestProcessorOn returns another processor sharing mr, like a second
+// instance of the API.
+func newTestProcessorOn(tb testing.TB, mr *miniredis.Miniredis) *PaymentProcessor {
+	tb.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tb.Cleanup(func() { client.Close() })
+	return NewPaymentProcessor(context.Background(), client)
+}
+
+// newTestProcessorServing returns a processor, up, whose default and
+// fallback are both handler.
+func newTestProcessorServing(tb testing.TB, handler http.HandlerFunc) (*PaymentProcessor, *miniredis.Miniredis) {
+	tb.Helper()
+	mr := miniredis.RunT(tb)
+	return newTestProcessorServingOn(tb, mr, handler), mr
+}
+
+// newTestProcessorServingOn is newTestProcessorServing sharing mr.
+func newTestProcessorServingOn(tb testing.TB, mr *miniredis.Miniredis, handler http.HandlerFunc) *PaymentProcessor {
+	tb.Helper()
+	server := httptest.NewServer(handler)
+	tb.Cleanup(server.Close)
+	tb.Setenv("PROCESSOR_DEFAULT_URL", server.URL)
+	tb.Setenv("PROCESSOR_FALLBACK_URL", server.URL)
+
+	p := newTestProcessorOn(tb, mr)
+	p.SetHTTPClient(server.Client())
+	p.SetUp(true)
+	return p
+}
+
+// closedURL is the address of a listener closed right away, so connecting to
+// it is refused.
+func closedURL(tb testing.TB) string {
+	tb.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	l.Close()
+	return "http://" + l.Addr().String()
+}
+
+// respondWith is a processor answering every request with status.
+func respondWith(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+}
+
+// testTask is a payment as the workers hand it to ProcessTask.
+func testTask(correlationId string) tasks.ProcessPaymentTask {
+	return tasks.ProcessPaymentTask{
+		ProcessPaymentPayload: tasks.ProcessPaymentPayload{
+			CorrelationId: correlationId,
+			Amount:        19.9,
+		},
+	}
+}
+
+// testRecord is the i-th of a series of payments, alternating processors.
+func testRecord(i int) models.PaymentRecord {
+	return models.PaymentRecord{
+		Version: models.PaymentRecordVersion,
+		ProcessPaymentTask: tasks.ProcessPaymentTask{
+			ProcessPaymentPayload: tasks.ProcessPaymentPayload{
+				CorrelationId: fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+				RequestedAt:   time.UnixMilli(testEpoch + int64(i)).UTC().Format(time.RFC3339Nano),
+				Amount:        19.9,
+			},
+			OnDefault: i%3 != 0,
+		},
+	}
+}
+
+// testEpoch is when testRecord's payments start, in unix millis.
+const testEpoch = 1_750_000_000_000
+
+// saveTestRecords saves n payments one milli apart from testEpoch.
+func saveTestRecords(tb testing.TB, p *PaymentProcessor, n int) {
+	tb.Helper()
+	for i := range n {
+		if err := p.store.Save(context.Background(), testEpoch+int64(i), testRecord(i)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// failCommands is a client hook failing the next n commands named name, like
+// "evalsha" or "mget", as if Redis had hiccuped.
+type failCommands struct {
+	name string
+	n    atomic.Int64
+}
+
+// failNext makes the next n commands named name on p's client fail.
+func failNext(p *PaymentProcessor, name string, n int64) {
+	hook := &failCommands{name: name}
+	hook.n.Store(n)
+	p.cache.AddHook(hook)
+}
+
+func (h *failCommands) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *failCommands) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == h.name && h.n.Add(-1) >= 0 {
+			err := errors.New("LOADING Redis is loading the dataset in memory")
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *failCommands) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
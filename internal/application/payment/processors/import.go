@@ -0,0 +1,131 @@
+package payment
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+	"github.com/redis/go-redis/v9"
+)
+
+const importBatchSize = 500
+
+// maxImportErrors caps the line errors kept in an ImportResult, so a file
+// full of bad lines doesn't make for a huge response. Skipped counts them all.
+const maxImportErrors = 100
+
+// ImportPayments loads NDJSON payment records, as produced by ExportPayments,
+// straight into Redis without calling the processors. Invalid lines are
+// skipped and counted, with why along with their line number.
+func (p *PaymentProcessor) ImportPayments(ctx context.Context, r io.Reader) (*models.ImportResult, error) {
+	if err := p.requireRedisStore(); err != nil {
+		return nil, err
+	}
+
+	res := models.ImportResult{}
+	pipe := p.cache.TxPipeline()
+	pending := 0
+	var changes []processorIndexChange
+
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		pipe.Incr(ctx, p.getPaymentsVersionKey())
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("error on importing payments: %w", err)
+		}
+		if len(changes) > 0 {
+			p.settleProcessorTotals(ctx, changes)
+			changes = changes[:0]
+		}
+		res.Imported += pending
+		pending = 0
+		return nil
+	}
+
+	skip := func(line int, err error) {
+		res.Skipped++
+		if len(res.Errors) < maxImportErrors {
+			res.Errors = append(res.Errors, models.ImportError{Line: line, Error: err.Error()})
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		record := models.PaymentRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			skip(lineNumber, fmt.Errorf("invalid json: %w", err))
+			continue
+		}
+		at, err := validImportRecord(record)
+		if err != nil {
+			skip(lineNumber, err)
+			continue
+		}
+
+		stored, err := p.codec.Marshal(record)
+		if err != nil {
+			skip(lineNumber, fmt.Errorf("error on encoding record: %w", err))
+			continue
+		}
+
+		k := p.getPaymentKey(record.CorrelationId)
+		pipe.Set(ctx, k, stored, 0)
+		p.zAddIndex(ctx, pipe, p.getPaymentsIndexKey(), redis.Z{
+			Score:  float64(at.UnixMilli()),
+			Member: k,
+		})
+		if change, ok := p.indexProcessorPayment(ctx, pipe, k, at.UnixMilli(), record.OnDefault, record.Amount); ok {
+			changes = append(changes, change)
+		}
+		if p.summaryCache != nil {
+			p.summaryCache.invalidate(at.UnixMilli())
+		}
+
+		pending++
+		if pending >= importBatchSize {
+			if err := flush(); err != nil {
+				return &res, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &res, fmt.Errorf("error on reading import: %w", err)
+	}
+
+	if err := flush(); err != nil {
+		return &res, err
+	}
+	return &res, nil
+}
+
+func validImportRecord(record models.PaymentRecord) (time.Time, error) {
+	if record.Version != models.PaymentRecordVersion {
+		return time.Time{}, fmt.Errorf("unsupported record version %d", record.Version)
+	}
+	if record.CorrelationId == "" {
+		return time.Time{}, errors.New("missing correlationId")
+	}
+	if record.Amount <= 0 {
+		return time.Time{}, errors.New("amount must be positive")
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, record.RequestedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid requestedAt: %w", err)
+	}
+	return at, nil
+}
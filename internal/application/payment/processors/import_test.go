@@ -0,0 +1,91 @@
+package payment
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+func importLine(tb testing.TB, record models.PaymentRecord) string {
+	tb.Helper()
+	line, err := json.Marshal(record)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(line)
+}
+
+func TestImportPaymentsReportsLineErrors(t *testing.T) {
+	p, mr := newTestProcessor(t)
+
+	oldVersion := testRecord(3)
+	oldVersion.Version = 0
+	noAmount := testRecord(4)
+	noAmount.Amount = 0
+	badTime := testRecord(5)
+	badTime.RequestedAt = "yesterday"
+	noID := testRecord(6)
+	noID.CorrelationId = ""
+
+	lines := []string{
+		importLine(t, testRecord(1)),
+		`{"correlationId":`,
+		"",
+		importLine(t, oldVersion),
+		importLine(t, testRecord(2)),
+		importLine(t, noAmount),
+		importLine(t, badTime),
+		importLine(t, noID),
+	}
+	res, err := p.ImportPayments(context.Background(), strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Imported != 2 || res.Skipped != 5 {
+		t.Errorf("imported %d and skipped %d, want 2 and 5", res.Imported, res.Skipped)
+	}
+	wantErrors := []struct {
+		line int
+		msg  string
+	}{
+		{2, "invalid json"},
+		{4, "unsupported record version 0"},
+		{6, "amount must be positive"},
+		{7, "invalid requestedAt"},
+		{8, "missing correlationId"},
+	}
+	if len(res.Errors) != len(wantErrors) {
+		t.Fatalf("errors = %+v, want %d of them", res.Errors, len(wantErrors))
+	}
+	for i, want := range wantErrors {
+		if got := res.Errors[i]; got.Line != want.line || !strings.Contains(got.Error, want.msg) {
+			t.Errorf("error %d = %+v, want line %d with %q", i, got, want.line, want.msg)
+		}
+	}
+
+	for _, i := range []int{1, 2} {
+		if !mr.Exists(p.getPaymentKey(testRecord(i).CorrelationId)) {
+			t.Errorf("valid payment %d not imported", i)
+		}
+	}
+}
+
+func TestImportPaymentsCapsLineErrors(t *testing.T) {
+	p, _ := newTestProcessor(t)
+
+	const bad = maxImportErrors + 50
+	res, err := p.ImportPayments(context.Background(), strings.NewReader(strings.Repeat("not json\n", bad)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Skipped != bad {
+		t.Errorf("skipped %d, want %d", res.Skipped, bad)
+	}
+	if len(res.Errors) != maxImportErrors {
+		t.Errorf("%d line errors kept, want %d", len(res.Errors), maxImportErrors)
+	}
+}
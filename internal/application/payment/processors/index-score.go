@@ -0,0 +1,40 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// IndexScoreEarliest keeps the earliest timestamp a payment was indexed
+	// with, so reprocessing it never moves it between summary windows.
+	IndexScoreEarliest = "earliest"
+	// IndexScoreLatest overwrites the timestamp on every save.
+	IndexScoreLatest = "latest"
+)
+
+// SetIndexScorePolicy decides which timestamp the date indexes keep when the
+// same payment is saved again, like after a requeue whose save had in fact
+// gone through. Defaults to IndexScoreEarliest.
+func (p *PaymentProcessor) SetIndexScorePolicy(policy string) error {
+	switch policy {
+	case IndexScoreEarliest:
+		p.indexLatestWins = false
+	case IndexScoreLatest:
+		p.indexLatestWins = true
+	default:
+		return fmt.Errorf("unknown index score policy %q", policy)
+	}
+	return nil
+}
+
+// zAddIndex queues a date index update on pipe following the score policy.
+// Its result counts the members that were not indexed yet.
+func (p *PaymentProcessor) zAddIndex(ctx context.Context, pipe redis.Pipeliner, key string, members ...redis.Z) *redis.IntCmd {
+	if p.indexLatestWins {
+		return pipe.ZAdd(ctx, key, members...)
+	}
+	return pipe.ZAddLT(ctx, key, members...)
+}
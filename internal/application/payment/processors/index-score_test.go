@@ -0,0 +1,58 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIndexScorePolicyOnReprocessing(t *testing.T) {
+	const correlationId = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	for _, mode := range []string{SaveModeLua, SaveModeTx, SaveModePipeline} {
+		for _, policy := range []string{IndexScoreEarliest, IndexScoreLatest} {
+			t.Run(fmt.Sprintf("%s %s", mode, policy), func(t *testing.T) {
+				p, mr := newTestProcessorServing(t, respondWith(http.StatusOK))
+				p.EnableProcessorIndexes()
+				if err := p.SetSaveMode(mode); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetIndexScorePolicy(policy); err != nil {
+					t.Fatal(err)
+				}
+				ctx := context.Background()
+				k := p.getPaymentKey(correlationId)
+
+				var scores []float64
+				for i := range 2 {
+					if i > 0 {
+						time.Sleep(5 * time.Millisecond)
+					}
+					if _, err := p.ProcessTask(ctx, testTask(correlationId)); err != nil {
+						t.Fatal(err)
+					}
+					score, err := mr.ZScore(p.getPaymentsIndexKey(), k)
+					if err != nil {
+						t.Fatal(err)
+					}
+					scores = append(scores, score)
+				}
+
+				want := scores[0]
+				if policy == IndexScoreLatest {
+					want = scores[1]
+					if scores[1] == scores[0] {
+						t.Fatal("the payment was processed twice within the same milli")
+					}
+				}
+				if scores[1] != want {
+					t.Errorf("index score %v after reprocessing, want %v", scores[1], want)
+				}
+				if score, err := mr.ZScore(p.getProcessorIndexKey(processorName(true)), k); err != nil || score != want {
+					t.Errorf("processor index score %v (%v), want %v", score, err, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"context"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+// EnableInstanceTagging stores the id of the instance that processed each
+// payment, for InstanceBreakdown. It costs the id's length per record.
+func (p *PaymentProcessor) EnableInstanceTagging(instanceID string) {
+	p.instanceID = instanceID
+}
+
+// InstanceBreakdown splits the summary of the window by the instance that
+// processed the payments, to spot load imbalance between instances. Payments
+// saved without tagging are counted under an empty id.
+func (p *PaymentProcessor) InstanceBreakdown(ctx context.Context, from, to int64) (map[string]*models.PaymentsSummaryResponse, error) {
+	res := map[string]*models.PaymentsSummaryResponse{}
+	err := p.store.Range(ctx, from, to, func(_ int64, payment models.PaymentRecord) {
+		if payment.Version != models.PaymentRecordVersion || payment.DryRun {
+			return
+		}
+
+		summary, ok := res[payment.ProcessedBy]
+		if !ok {
+			summary = &models.PaymentsSummaryResponse{}
+			res[payment.ProcessedBy] = summary
+		}
+		if payment.OnDefault {
+			summary.Default.TotalRequests++
+			summary.Default.TotalAmount += payment.Amount
+			return
+		}
+		summary.Fallback.TotalRequests++
+		summary.Fallback.TotalAmount += payment.Amount
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, summary := range res {
+		summary.Default.TotalAmount = roundAmount(summary.Default.TotalAmount, p.precision)
+		summary.Fallback.TotalAmount = roundAmount(summary.Fallback.TotalAmount, p.precision)
+	}
+	return res, nil
+}
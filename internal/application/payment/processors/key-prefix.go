@@ -0,0 +1,25 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetKeyPrefix puts every key, channel and health check key this processor
+// uses in Redis under prefix, so independent runs can share one instance. The
+// up flag is reloaded from the prefixed key. Call it before SetHealthCheckPair
+// and before anything touches Redis.
+func (p *PaymentProcessor) SetKeyPrefix(ctx context.Context, prefix string) {
+	p.keyPrefix = prefix
+	if prefix == "" {
+		return
+	}
+
+	up, _ := p.cache.Get(ctx, p.getHealthCheckKey(HEALTH_CHECK_KEY)).Bool()
+	fmt.Printf("redis key prefix %s, initializing up with %t\n", prefix, up)
+	p.SetUp(up)
+}
+
+func (p *PaymentProcessor) prefixKey(key string) string {
+	return p.keyPrefix + key
+}
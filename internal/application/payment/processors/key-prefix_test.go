@@ -0,0 +1,78 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+)
+
+func TestKeyPrefixIsolatesProcessors(t *testing.T) {
+	mr := miniredis.RunT(t)
+	healthy := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/payments/service-health" {
+			w.Write([]byte(`{"failing":false,"minResponseTime":0}`))
+		}
+	}
+	ctx := context.Background()
+
+	a := newTestProcessorServingOn(t, mr, healthy)
+	a.SetKeyPrefix(ctx, "run-a:")
+	a.SetUp(true)
+	b := newTestProcessorServingOn(t, mr, healthy)
+	b.SetKeyPrefix(ctx, "run-b:")
+	b.SetUp(true)
+
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	if _, err := a.ProcessTask(ctx, testTask(id)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeadLetter(ctx, testTask("dead"), "max retries reached", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ParkQueued(ctx, []queue.Item{{Data: []byte(`{}`), EnqueuedAt: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	a.HealthCheck(ctx, true)
+
+	if _, err := a.GetPayment(ctx, id); err != nil {
+		t.Errorf("payment not found under its own prefix: %v", err)
+	}
+	if _, err := b.GetPayment(ctx, id); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("other prefix got payment error %v, want ErrPaymentNotFound", err)
+	}
+
+	from, to := time.Now().Add(-time.Hour).UnixMilli(), time.Now().Add(time.Hour).UnixMilli()
+	for _, opts := range []SummaryOptions{{}, {Latency: true}} {
+		for _, tt := range []struct {
+			p    *PaymentProcessor
+			want int
+		}{{a, 1}, {b, 0}} {
+			summary, err := tt.p.SummaryPayments(ctx, from, to, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary.Default.TotalRequests + summary.Fallback.TotalRequests; got != tt.want {
+				t.Errorf("prefix %s, latency %t: %d payments in the summary, want %d", tt.p.keyPrefix, opts.Latency, got, tt.want)
+			}
+		}
+	}
+
+	if _, total, err := b.DeadLetters(ctx, 0, 10); err != nil || total != 0 {
+		t.Errorf("other prefix sees %d dead letters (err %v), want none", total, err)
+	}
+	if parked, err := b.PopParked(ctx, 10); err != nil || len(parked) != 0 {
+		t.Errorf("other prefix popped %d parked tasks (err %v), want none", len(parked), err)
+	}
+
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "run-a:") {
+			t.Errorf("key %q written outside the prefix", key)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package payment
+
+import (
+	"sort"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+func latencySummary(durations []float64) *models.LatencySummary {
+	if len(durations) == 0 {
+		return &models.LatencySummary{}
+	}
+
+	sort.Float64s(durations)
+	return &models.LatencySummary{
+		P50: percentile(durations, 50),
+		P99: percentile(durations, 99),
+	}
+}
+
+// percentile uses the nearest-rank method over an already sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
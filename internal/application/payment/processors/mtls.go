@@ -0,0 +1,44 @@
+package payment
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// EnableMutualTLS presents the given client certificate to the processors and,
+// when caFile is set, only trusts processor certificates signed by that CA.
+// Either pair may be left empty to only set the other one.
+func (p *PaymentProcessor) EnableMutualTLS(certFile, keyFile, caFile string) error {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("error on loading processor client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("error on reading processor CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return errors.New("error on parsing processor CA certificate: no certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport, ok := p.client.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("error on enabling mutual TLS: unsupported transport %T", p.client.Transport)
+	}
+	transport.TLSClientConfig = tlsConfig
+	return nil
+}
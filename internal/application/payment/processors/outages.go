@@ -0,0 +1,72 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// HEALTH_CHECK_OUTAGES_KEY is a sorted set of the default processor's up and
+// down transitions, scored by unix millis, as seen by the master health checks.
+const HEALTH_CHECK_OUTAGES_KEY = "health_check:outages"
+
+func (p *PaymentProcessor) recordHealthTransition(ctx context.Context, up bool) {
+	state := "down"
+	if up {
+		state = "up"
+	}
+	at := p.now().UnixMilli()
+	err := p.cache.ZAdd(ctx, p.getHealthCheckKey(HEALTH_CHECK_OUTAGES_KEY), redis.Z{
+		Score:  float64(at),
+		Member: fmt.Sprintf("%s:%d", state, at),
+	}).Err()
+	if err != nil {
+		fmt.Println("failed to record health transition:", err)
+	}
+}
+
+// Outages returns the windows the default processor was down that overlap
+// from and to, in unix millis. Totals over them may undercount.
+func (p *PaymentProcessor) Outages(ctx context.Context, from, to int64) ([]models.OutageWindow, error) {
+	transitions, err := p.cache.ZRangeByScoreWithScores(ctx, p.getHealthCheckKey(HEALTH_CHECK_OUTAGES_KEY), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprint(to),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error on getting outages: %w", err)
+	}
+
+	var outages []models.OutageWindow
+	downSince := int64(-1)
+	for _, t := range transitions {
+		at := int64(t.Score)
+		if strings.HasPrefix(t.Member.(string), "down:") {
+			if downSince < 0 {
+				downSince = at
+			}
+			continue
+		}
+		if downSince < 0 {
+			continue
+		}
+		if at >= from {
+			outages = append(outages, models.OutageWindow{
+				From: formatMillis(downSince),
+				To:   formatMillis(at),
+			})
+		}
+		downSince = -1
+	}
+	if downSince >= 0 {
+		outages = append(outages, models.OutageWindow{From: formatMillis(downSince)})
+	}
+	return outages, nil
+}
+
+func formatMillis(millis int64) string {
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339Nano)
+}
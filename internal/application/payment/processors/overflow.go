@@ -0,0 +1,57 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// PushOverflow parks a raw task in Redis when the in-memory queue is full.
+func (p *PaymentProcessor) PushOverflow(ctx context.Context, task []byte) error {
+	value := encodeQueuedTask(queue.Item{Data: task, EnqueuedAt: time.Now()})
+	if err := p.cache.RPush(ctx, p.getOverflowKey(), value).Err(); err != nil {
+		return fmt.Errorf("error on pushing overflow task: %w", err)
+	}
+	return nil
+}
+
+// PopOverflow takes up to n of the oldest overflowed tasks, with when they
+// were first accepted.
+func (p *PaymentProcessor) PopOverflow(ctx context.Context, n int) ([]queue.Item, error) {
+	values, err := p.cache.LPopCount(ctx, p.getOverflowKey(), n).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error on popping overflow tasks: %w", err)
+	}
+	return decodeQueuedTasks(values), nil
+}
+
+// RequeueOverflow puts tasks back at the head of the overflow list, keeping their order.
+func (p *PaymentProcessor) RequeueOverflow(ctx context.Context, overflowed []queue.Item) error {
+	if err := p.pushFront(ctx, p.getOverflowKey(), overflowed); err != nil {
+		return fmt.Errorf("error on requeueing overflow tasks: %w", err)
+	}
+	return nil
+}
+
+// pushFront puts tasks at the head of the list at key, keeping their order.
+func (p *PaymentProcessor) pushFront(ctx context.Context, key string, tasks []queue.Item) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	values := encodeQueuedTasks(tasks)
+	// LPUSH prepends one by one, so push in reverse
+	slices.Reverse(values)
+	return p.cache.LPush(ctx, key, values...).Err()
+}
+
+func (p *PaymentProcessor) getOverflowKey() string {
+	return p.prefixKey("payments:overflow")
+}
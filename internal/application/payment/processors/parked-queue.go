@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// ParkQueued saves tasks left in the in-memory queue at shutdown, so the next
+// instance to start picks them up instead of them being lost. Their enqueue
+// time is kept along with them.
+func (p *PaymentProcessor) ParkQueued(ctx context.Context, tasks []queue.Item) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if err := p.cache.RPush(ctx, p.getParkedKey(), encodeQueuedTasks(tasks)...).Err(); err != nil {
+		return fmt.Errorf("error on parking queued tasks: %w", err)
+	}
+	return nil
+}
+
+// PopParked takes up to n of the oldest parked tasks.
+func (p *PaymentProcessor) PopParked(ctx context.Context, n int) ([]queue.Item, error) {
+	values, err := p.cache.LPopCount(ctx, p.getParkedKey(), n).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error on popping parked tasks: %w", err)
+	}
+	return decodeQueuedTasks(values), nil
+}
+
+// UnpopParked puts tasks back at the head of the parked list, keeping their order.
+func (p *PaymentProcessor) UnpopParked(ctx context.Context, tasks []queue.Item) error {
+	if err := p.pushFront(ctx, p.getParkedKey(), tasks); err != nil {
+		return fmt.Errorf("error on unpopping parked tasks: %w", err)
+	}
+	return nil
+}
+
+func (p *PaymentProcessor) getParkedKey() string {
+	return p.prefixKey("payments:parked")
+}
@@ -3,26 +3,92 @@ package payment
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
-	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	json "github.com/json-iterator/go"
 	models "github.com/payment-processor-rinha/internal/application/payment/models"
 	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+	"github.com/payment-processor-rinha/internal/money"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
 )
 
 type PaymentProcessor struct {
-	client      *http.Client
-	cache       *redis.Client
-	defaultURL  string
-	fallbackURL string
-	up          bool
-	upMutex     sync.RWMutex
+	client *http.Client
+	cache  *redis.Client
+	// chain is swapped whole by ReloadConfig, so readers never need a lock
+	chain atomic.Pointer[processorChain]
+
+	up         bool
+	upOverride bool
+	upMutex    sync.RWMutex
+	// upSignal is closed while up and replaced by an open one when going down,
+	// so WaitUp callers all wake at once on recovery.
+	upSignal chan struct{}
+
+	routingPolicy    string
+	rampWindow       time.Duration
+	recoveredAt      time.Time
+	fallbackDisabled bool
+
+	summaryCache  *summaryCache
+	precision     int
+	snapshot      *summarySnapshot
+	snapshotMutex sync.RWMutex
+
+	// bounded in-flight requests per processor, nil means unlimited
+	defaultSem  chan struct{}
+	fallbackSem chan struct{}
+
+	// summaries computed at once and how long to queue for a slot, nil means unlimited
+	summarySem  chan struct{}
+	summaryWait time.Duration
+
+	// statuses that mean the processor took the payment, nil means 200, 201 and 202
+	defaultSuccessStatuses  []int
+	fallbackSuccessStatuses []int
+
+	// outbound requests per second per processor, nil means unlimited
+	defaultLimiter  *rate.Limiter
+	fallbackLimiter *rate.Limiter
+
+	coalescer        *writeCoalescer
+	totals           *totalsBuffer
+	store            PaymentStore
+	saveMode         string
+	saveRetries      int
+	saveRetryBackoff time.Duration
+	codec            RecordCodec
+	processorIndexes bool
+	indexLatestWins  bool
+	dryRun           bool
+	recoveryProbes   int
+	healthCheckPair  string
+	keyPrefix        string
+	coldStartUp      bool
+	// only touched by the health check goroutine of the master
+	unreachableSince time.Time
+	unreachableGrace time.Duration
+	timing           bool
+	instanceID       string
+	redisClock       bool
+	paymentEvents    bool
+	// clockOffset is Redis' clock minus ours, in nanoseconds
+	clockOffset atomic.Int64
+	// pendingSaves counts savePayment calls that haven't returned yet
+	pendingSaves atomic.Int64
+
+	redisUp    bool
+	redisMutex sync.RWMutex
+	// redisSignal follows the same closed-while-up scheme as upSignal
+	redisSignal chan struct{}
 }
 
 func NewPaymentProcessor(ctx context.Context, cache *redis.Client) *PaymentProcessor {
@@ -31,12 +97,191 @@ func NewPaymentProcessor(ctx context.Context, cache *redis.Client) *PaymentProce
 
 	fmt.Printf("initializing up with %t\n", up)
 
-	return &PaymentProcessor{
-		client:      &http.Client{},
-		cache:       cache,
-		defaultURL:  os.Getenv("PROCESSOR_DEFAULT_URL"),
-		fallbackURL: os.Getenv("PROCESSOR_FALLBACK_URL"),
-		up:          up,
+	upSignal := make(chan struct{})
+	if up {
+		close(upSignal)
+	}
+
+	p := &PaymentProcessor{
+		client:    newHTTPClient(),
+		cache:     cache,
+		up:        up,
+		upSignal:  upSignal,
+		precision: 2,
+
+		recoveryProbes: 1,
+		saveMode:       SaveModeLua,
+		codec:          jsonCodec{},
+
+		routingPolicy: RoutingImmediate,
+
+		redisUp:     true,
+		redisSignal: make(chan struct{}),
+	}
+	close(p.redisSignal)
+	chain, err := loadProcessorChain()
+	if err != nil {
+		// better not to start than to send payments somewhere unintended
+		panic(err)
+	}
+	p.chain.Store(chain)
+	p.store = &redisStore{p: p}
+	return p
+}
+
+// UseFileStore saves payments to an append-only file instead of Redis. Only
+// meant for single-instance runs. Export, lookups by correlationId, import
+// and compaction then fail with ErrUnsupportedStore.
+func (p *PaymentProcessor) UseFileStore(path string) error {
+	store, err := newFileStore(path)
+	if err != nil {
+		return err
+	}
+	p.store = store
+	return nil
+}
+
+// SetSummaryPrecision sets how many decimal places summary amounts are
+// rounded to. Only 1 and 2 are supported.
+func (p *PaymentProcessor) SetSummaryPrecision(precision int) error {
+	if precision != 1 && precision != 2 {
+		return fmt.Errorf("unsupported summary precision %d", precision)
+	}
+	p.precision = precision
+	return nil
+}
+
+// EnableSummaryCache keeps computed summaries for ttl, keyed by the requested
+// window rounded down to granularity. A cached summary only answers a
+// different exact window when no payment falls between the two. A saved
+// payment invalidates every cached window.
+func (p *PaymentProcessor) EnableSummaryCache(ttl, granularity time.Duration) {
+	p.summaryCache = newSummaryCache(ttl, granularity)
+}
+
+// newHTTPClient negotiates HTTP/2 through ALPN when the processor offers it,
+// multiplexing the workers' POSTs, and stays on HTTP/1.1 otherwise.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 100
+
+	return &http.Client{
+		Transport: transport,
+		// a redirected POST may be replayed as a GET, surface it instead
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// SetHTTPClient replaces the client used for payments, health checks and the
+// processors' admin endpoints, like one aimed at an httptest.Server or with a
+// transport simulating failures. The default one comes from newHTTPClient; a
+// replacement should not follow redirects either. The Redis client is already
+// the one given to NewPaymentProcessor.
+func (p *PaymentProcessor) SetHTTPClient(client *http.Client) {
+	p.client = client
+}
+
+// EnableWriteCoalescing batches payment saves from all workers into one
+// Redis transaction every interval, or sooner once maxBatch saves are waiting.
+func (p *PaymentProcessor) EnableWriteCoalescing(interval time.Duration, maxBatch int) {
+	p.coalescer = newWriteCoalescer(p, interval, maxBatch)
+}
+
+// Close flushes any buffered writes. It must be called once workers are done.
+func (p *PaymentProcessor) Close() {
+	if err := p.store.Close(); err != nil {
+		fmt.Println("failed to close payment store:", err)
+	}
+	if p.totals != nil {
+		p.totals.close()
+	}
+}
+
+// SetProcessorConcurrency caps in-flight requests to each processor. Zero
+// leaves that processor unlimited.
+func (p *PaymentProcessor) SetProcessorConcurrency(defaultMax, fallbackMax int) {
+	if defaultMax > 0 {
+		p.defaultSem = make(chan struct{}, defaultMax)
+	}
+	if fallbackMax > 0 {
+		p.fallbackSem = make(chan struct{}, fallbackMax)
+	}
+}
+
+// SetMaxConcurrentSummaries caps how many summaries are computed at once, so
+// a burst of them can't take every Redis connection from the save path. Extra
+// ones wait up to wait for a slot and then fail with ErrSummaryBusy. Zero
+// leaves summaries unlimited.
+func (p *PaymentProcessor) SetMaxConcurrentSummaries(maxSummaries int, wait time.Duration) {
+	if maxSummaries > 0 {
+		p.summarySem = make(chan struct{}, maxSummaries)
+	}
+	p.summaryWait = wait
+}
+
+func (p *PaymentProcessor) acquireSummary(ctx context.Context) (release func(), err error) {
+	if p.summarySem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case p.summarySem <- struct{}{}:
+		return func() { <-p.summarySem }, nil
+	default:
+	}
+
+	timer := time.NewTimer(p.summaryWait)
+	defer timer.Stop()
+	select {
+	case p.summarySem <- struct{}{}:
+		return func() { <-p.summarySem }, nil
+	case <-timer.C:
+		return nil, ErrSummaryBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SetProcessorRateLimit caps requests per second to each processor. Zero
+// leaves that processor unlimited.
+func (p *PaymentProcessor) SetProcessorRateLimit(defaultRPS, fallbackRPS int) {
+	if defaultRPS > 0 {
+		p.defaultLimiter = rate.NewLimiter(rate.Limit(defaultRPS), defaultRPS)
+	}
+	if fallbackRPS > 0 {
+		p.fallbackLimiter = rate.NewLimiter(rate.Limit(fallbackRPS), fallbackRPS)
+	}
+}
+
+func (p *PaymentProcessor) waitRateLimit(ctx context.Context, onDefault bool) error {
+	limiter := p.fallbackLimiter
+	if onDefault {
+		limiter = p.defaultLimiter
+	}
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
+
+func (p *PaymentProcessor) acquire(ctx context.Context, onDefault bool) (release func(), err error) {
+	sem := p.fallbackSem
+	if onDefault {
+		sem = p.defaultSem
+	}
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
@@ -46,135 +291,352 @@ func (p *PaymentProcessor) IsUp() bool {
 	return p.up
 }
 
+// WaitUp blocks until the processor is up or ctx is done. An up processor
+// always wins, even once ctx is done.
+func (p *PaymentProcessor) WaitUp(ctx context.Context) error {
+	p.upMutex.RLock()
+	upSignal := p.upSignal
+	p.upMutex.RUnlock()
+
+	// like WaitRedis, check the signal alone before racing it with ctx
+	select {
+	case <-upSignal:
+		return nil
+	default:
+	}
+	select {
+	case <-upSignal:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (p *PaymentProcessor) SetUp(status bool) {
 	p.upMutex.Lock()
 	defer p.upMutex.Unlock()
+	p.setUpLocked(status)
+}
+
+func (p *PaymentProcessor) setUpLocked(status bool) {
+	if status == p.up {
+		return
+	}
 	p.up = status
+	if status {
+		p.recoveredAt = time.Now()
+		close(p.upSignal)
+		return
+	}
+	p.upSignal = make(chan struct{})
+}
+
+// OverrideUp pins the up flag so health checks stop updating it until
+// ClearUpOverride is called.
+func (p *PaymentProcessor) OverrideUp(status bool) {
+	p.upMutex.Lock()
+	defer p.upMutex.Unlock()
+	p.setUpLocked(status)
+	p.upOverride = true
+}
+
+func (p *PaymentProcessor) ClearUpOverride() {
+	p.upMutex.Lock()
+	defer p.upMutex.Unlock()
+	p.upOverride = false
 }
 
-func (p *PaymentProcessor) ProcessTask(ctx context.Context, task tasks.ProcessPaymentTask) error {
+func (p *PaymentProcessor) setUpFromHealthCheck(status bool) {
+	p.upMutex.Lock()
+	defer p.upMutex.Unlock()
+	if p.upOverride {
+		return
+	}
+	p.setUpLocked(status)
+}
+
+// ProcessTask sends the payment to the current processor and returns the
+// record as it was saved. Errors wrap ErrRetryable, ErrProcessorDown or
+// ErrPermanent so callers can decide whether to try again.
+func (p *PaymentProcessor) ProcessTask(ctx context.Context, task tasks.ProcessPaymentTask) (*tasks.ProcessPaymentTask, error) {
 	// fmt.Printf("processing payment cid %s\n", task.CorrelationId)
-	now := time.Now().UTC()
+	now := p.now().UTC()
 	task.RequestedAt = now.Format(time.RFC3339Nano)
+	task.OnDefault = p.useDefault()
+	if task.ForcedProcessor != "" && !p.fallbackDisabled {
+		task.OnDefault = task.ForcedProcessor == tasks.ProcessorDefault
+	}
+
+	if p.dryRun {
+		if err := p.savePayment(ctx, now, 0, &task); err != nil {
+			fmt.Println("failed to save payment:", err)
+			return nil, fmt.Errorf("%w: %w", ErrRetryable, err)
+		}
+		return &task, nil
+	}
 
 	jsonData, err := json.Marshal(task)
 
 	if err != nil {
 		fmt.Println("failed to marshal payment:", err)
-		return err
+		return nil, fmt.Errorf("%w: %w", ErrPermanent, err)
+	}
+
+	release, err := p.acquire(ctx, task.OnDefault)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRetryable, err)
+	}
+	defer release()
+
+	if err := p.waitRateLimit(ctx, task.OnDefault); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRetryable, err)
 	}
 
-	res := &http.Response{}
-	res, err = p.client.Post(p.baseURL()+"/payments", "application/json", bytes.NewBuffer(jsonData))
+	startedAt := time.Now()
+	task.Tier = p.pickTier(task.OnDefault)
+	if timeout := p.chain.Load().requestTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tierURL(task.Tier)+"/payments", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := p.client.Do(req)
+	duration := time.Since(startedAt)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// only this try ran out of time, a slow processor is not a down one
+		fmt.Println("payment request timed out:", err)
+		return nil, fmt.Errorf("%w: %w", ErrRetryable, err)
+	}
 	if err != nil {
 		fmt.Println("failed to send request:", err)
-		return err
+		p.markTierDown(task.Tier)
+		// an unreachable default is a stronger signal than a 5xx, stop routing
+		// to it until the next health check says otherwise
+		if task.OnDefault && task.ForcedProcessor == "" {
+			p.setUpFromHealthCheck(false)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrProcessorDown, err)
 	}
 	defer res.Body.Close()
 
 	if p.isRetryableError(res.StatusCode) {
-		err = fmt.Errorf("processing error status: %s %s", res.Status, res.Body)
+		p.markTierDown(task.Tier)
+		err = fmt.Errorf("%w: status %s", ErrRetryable, res.Status)
 		fmt.Println(err)
-		return err
+		return nil, err
 	}
 
-	if res.StatusCode == http.StatusOK {
-		err := p.savePayment(ctx, now, &task)
-		if err != nil {
-			fmt.Println("failed to save payment:", err)
-			return nil
+	if res.StatusCode/100 == 3 {
+		err = fmt.Errorf("%w: unexpected redirect status %s", ErrPermanent, res.Status)
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if !p.isSuccessStatus(task.OnDefault, res.StatusCode) {
+		if res.StatusCode/100 == 2 {
+			// the processor may well have charged it, someone has to reconcile
+			err = fmt.Errorf("%w: %w %s for payment %s", ErrPermanent, ErrUnexpectedSuccess, res.Status, task.CorrelationId)
+			fmt.Println(err)
+			return nil, err
 		}
-		return nil
+		return nil, fmt.Errorf("%w: status %s", ErrPermanent, res.Status)
 	}
 
-	return nil
+	// the try's deadline is for the processor, the save must go through
+	if err := p.savePayment(context.WithoutCancel(ctx), now, duration, &task); err != nil {
+		// the processor already accepted it, retrying would charge twice
+		fmt.Println("failed to save payment:", err)
+		return nil, fmt.Errorf("%w: %w: %w", ErrPermanent, ErrNotRecorded, err)
+	}
+	return &task, nil
 }
 
-func (p *PaymentProcessor) SummaryPayments(ctx context.Context, from, to int64) (*models.PaymentsSummaryResponse, error) {
-	res := models.PaymentsSummaryResponse{}
+type SummaryOptions struct {
+	// Latency adds per-processor duration percentiles to the summary.
+	Latency bool
+	// OmitEmpty leaves processors without payments out of the response. The
+	// summary itself is the same, it is kept here so ETags and cached entries
+	// tell both shapes apart.
+	OmitEmpty bool
+}
 
-	keys, err := p.cache.ZRangeByScore(ctx, p.getPaymentsIndexKey(), &redis.ZRangeBy{
-		Min: fmt.Sprint(from),
-		Max: fmt.Sprint(to),
-	}).Result()
-	if err != nil {
-		fmt.Println(err)
-		return nil, fmt.Errorf("failed to get payments to summarize")
+func (p *PaymentProcessor) SummaryPayments(ctx context.Context, from, to int64, opts SummaryOptions) (*models.PaymentsSummaryResponse, error) {
+	if p.summaryCache == nil {
+		return p.summaryPayments(ctx, from, to, opts)
+	}
+
+	version, err := p.cache.Get(ctx, p.getPaymentsVersionKey()).Int64()
+	if err != nil && err != redis.Nil {
+		fmt.Println("failed to get payments version:", err)
+		return p.summaryPayments(ctx, from, to, opts)
 	}
 
-	fmt.Println("payments len:", len(keys))
-	if len(keys) == 0 {
-		return &res, nil
+	if cached, ok := p.summaryCache.get(from, to, opts, version); ok {
+		if p.samePayments(ctx, from, to, cached.from, cached.to) {
+			summary := cached.summary
+			return &summary, nil
+		}
 	}
 
-	results, err := p.cache.MGet(ctx, keys...).Result()
+	res, err := p.summaryPayments(ctx, from, to, opts)
 	if err != nil {
-		fmt.Println(err)
-		return nil, fmt.Errorf("failed to get payments")
+		return nil, err
 	}
+	p.summaryCache.set(from, to, opts, version, res)
+	return res, nil
+}
 
-	for _, result := range results {
-		if result == nil {
-			continue
-		}
-		payment := tasks.ProcessPaymentTask{}
-		err := json.Unmarshal([]byte(result.(string)), &payment)
+func (p *PaymentProcessor) summaryPayments(ctx context.Context, from, to int64, opts SummaryOptions) (*models.PaymentsSummaryResponse, error) {
+	release, err := p.acquireSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if _, onRedis := p.store.(*redisStore); onRedis && !opts.Latency {
+		totals, ok, err := p.processorTotals(ctx, from, to)
 		if err != nil {
-			continue
+			fmt.Println(err)
+		}
+		if ok {
+			totals.Default.TotalAmount = roundAmount(totals.Default.TotalAmount, p.precision)
+			totals.Fallback.TotalAmount = roundAmount(totals.Fallback.TotalAmount, p.precision)
+			return totals, nil
+		}
+	}
+
+	res := models.PaymentsSummaryResponse{}
+
+	skipped := 0
+	var defaultDurations, fallbackDurations []float64
+	var defaultAmount, fallbackAmount, dryRunAmount money.Sum
+	err = p.store.Range(ctx, from, to, func(_ int64, payment models.PaymentRecord) {
+		if payment.Version != models.PaymentRecordVersion {
+			skipped++
+			return
+		}
+
+		if payment.DryRun {
+			if res.DryRun == nil {
+				res.DryRun = &models.PaymentsSummary{}
+			}
+			res.DryRun.TotalRequests++
+			dryRunAmount.Add(payment.Amount)
+			return
 		}
 
 		if payment.OnDefault {
 			res.Default.TotalRequests++
-			res.Default.TotalAmount += payment.Amount
-			continue
+			defaultAmount.Add(payment.Amount)
+			if opts.Latency && payment.DurationMs > 0 {
+				defaultDurations = append(defaultDurations, payment.DurationMs)
+			}
+			return
 		}
 
 		res.Fallback.TotalRequests++
-		res.Fallback.TotalAmount += payment.Amount
+		fallbackAmount.Add(payment.Amount)
+		if opts.Latency && payment.DurationMs > 0 {
+			fallbackDurations = append(fallbackDurations, payment.DurationMs)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Latency {
+		res.Default.Latency = latencySummary(defaultDurations)
+		res.Fallback.Latency = latencySummary(fallbackDurations)
+	}
+
+	if skipped > 0 {
+		fmt.Printf("skipped %d payment records with unexpected version\n", skipped)
 	}
 
-	res.Default.TotalAmount = math.Round(res.Default.TotalAmount*10) / 10
-	res.Fallback.TotalAmount = math.Round(res.Fallback.TotalAmount*10) / 10
+	res.Default.TotalAmount = defaultAmount.Round(p.precision)
+	res.Fallback.TotalAmount = fallbackAmount.Round(p.precision)
+	if res.DryRun != nil {
+		res.DryRun.TotalAmount = dryRunAmount.Round(p.precision)
+	}
 
 	return &res, nil
 }
 
 func (p *PaymentProcessor) baseURL() string {
-	if p.IsUp() {
-		return p.defaultURL
-	}
-	return p.fallbackURL
+	return p.tierURL(p.pickTier(p.useDefault()))
 }
 
 func (p *PaymentProcessor) getPaymentKey(correlationId string) string {
-	return "payments:" + correlationId
+	return p.prefixKey("payments:" + correlationId)
 }
 
 func (p *PaymentProcessor) getPaymentsIndexKey() string {
-	return "payments:by-date"
+	return p.prefixKey("payments:by-date")
 }
 
-func (p *PaymentProcessor) savePayment(ctx context.Context, now time.Time, payload *tasks.ProcessPaymentTask) error {
-	payload.OnDefault = p.IsUp()
-	j, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("error on marshalling processed payment: %w", err)
+func (p *PaymentProcessor) getPaymentsVersionKey() string {
+	return p.prefixKey("payments:version")
+}
+
+func (p *PaymentProcessor) savePayment(ctx context.Context, now time.Time, duration time.Duration, payload *tasks.ProcessPaymentTask) error {
+	p.pendingSaves.Add(1)
+	defer p.pendingSaves.Add(-1)
+
+	record := models.PaymentRecord{
+		Version:            models.PaymentRecordVersion,
+		ProcessPaymentTask: *payload,
+		DurationMs:         float64(duration.Microseconds()) / 1000,
+		DryRun:             p.dryRun,
+		Timing:             p.paymentTiming(payload, time.Now()),
+		Tier:               payload.Tier,
+		ProcessedBy:        p.instanceID,
+	}
+	if err := p.saveRecord(ctx, now.UnixMilli(), record); err != nil {
+		return err
 	}
+	p.publishPaymentEvent(ctx, record)
 
-	k := p.getPaymentKey(payload.CorrelationId)
-	pipe := p.cache.TxPipeline()
-	pipe.Set(ctx, k, j, 0)
-	pipe.ZAdd(ctx, p.getPaymentsIndexKey(), redis.Z{
-		Score:  float64(now.UnixMilli()),
-		Member: k,
-	})
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("error on saving processed payments: %w", err)
+	if p.summaryCache != nil {
+		p.summaryCache.invalidate(now.UnixMilli())
 	}
 	return nil
 }
 
+func roundAmount(amount float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Round(amount*scale) / scale
+}
+
 func (p *PaymentProcessor) isRetryableError(statusCode int) bool {
-	return statusCode/100 == 5
+	return statusCode/100 == 5 || statusCode == http.StatusTooManyRequests
+}
+
+// SetSuccessStatuses sets which statuses mean the default and the fallback
+// processors took a payment. A nil list keeps the 200, 201 and 202 default.
+// The extra fallback tiers follow the fallback's list.
+func (p *PaymentProcessor) SetSuccessStatuses(defaultStatuses, fallbackStatuses []int) {
+	p.defaultSuccessStatuses = defaultStatuses
+	p.fallbackSuccessStatuses = fallbackStatuses
+}
+
+// isSuccessStatus reports whether the processor took the payment, which is
+// when we must save it.
+func (p *PaymentProcessor) isSuccessStatus(onDefault bool, statusCode int) bool {
+	statuses := p.fallbackSuccessStatuses
+	if onDefault {
+		statuses = p.defaultSuccessStatuses
+	}
+	if statuses != nil {
+		return slices.Contains(statuses, statusCode)
+	}
+
+	switch statusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+		return true
+	}
+	return false
 }
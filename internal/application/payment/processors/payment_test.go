@@ -0,0 +1,181 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProcessTaskStatuses(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   error
+		wantSaved bool
+	}{
+		{status: http.StatusOK, wantSaved: true},
+		{status: http.StatusCreated, wantSaved: true},
+		{status: http.StatusAccepted, wantSaved: true},
+		{status: http.StatusFound, wantErr: ErrPermanent},
+		{status: http.StatusTemporaryRedirect, wantErr: ErrPermanent},
+		{status: http.StatusBadRequest, wantErr: ErrPermanent},
+		{status: http.StatusUnprocessableEntity, wantErr: ErrPermanent},
+		{status: http.StatusTooManyRequests, wantErr: ErrRetryable},
+		{status: http.StatusInternalServerError, wantErr: ErrRetryable},
+		{status: http.StatusServiceUnavailable, wantErr: ErrRetryable},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			p, mr := newTestProcessorServing(t, respondWith(tt.status))
+
+			processed, err := p.ProcessTask(context.Background(), testTask("status"))
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("ProcessTask error = %v, want none", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProcessTask error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && processed != nil {
+				t.Errorf("ProcessTask returned %+v along with an error, want no task", processed)
+			}
+			if tt.wantErr == nil && (processed == nil || processed.CorrelationId != "status") {
+				t.Errorf("ProcessTask returned %+v, want the processed task", processed)
+			}
+
+			if saved := mr.Exists(p.getPaymentKey("status")); saved != tt.wantSaved {
+				t.Errorf("payment saved = %t, want %t", saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestProcessTaskReturnsProcessedTask(t *testing.T) {
+	tests := []struct {
+		name          string
+		up            bool
+		wantOnDefault bool
+		wantTier      int
+	}{
+		{name: "default", up: true, wantOnDefault: true, wantTier: 0},
+		{name: "fallback", up: false, wantOnDefault: false, wantTier: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestProcessorServing(t, respondWith(http.StatusOK))
+			p.SetUp(tt.up)
+
+			before := time.Now().UTC()
+			processed, err := p.ProcessTask(context.Background(), testTask("returned"))
+			after := time.Now().UTC()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if processed.CorrelationId != "returned" || processed.Amount != 19.9 {
+				t.Errorf("processed payment %+v, want the one handed in", processed.ProcessPaymentPayload)
+			}
+			if processed.OnDefault != tt.wantOnDefault {
+				t.Errorf("OnDefault = %t, want %t", processed.OnDefault, tt.wantOnDefault)
+			}
+			if processed.Tier != tt.wantTier {
+				t.Errorf("Tier = %d, want %d", processed.Tier, tt.wantTier)
+			}
+			requestedAt, err := time.Parse(time.RFC3339Nano, processed.RequestedAt)
+			if err != nil {
+				t.Fatalf("RequestedAt %q: %v", processed.RequestedAt, err)
+			}
+			if requestedAt.Before(before) || requestedAt.After(after) {
+				t.Errorf("RequestedAt = %s, want between %s and %s", requestedAt, before, after)
+			}
+		})
+	}
+}
+
+func TestProcessTaskUnlistedSuccessStatus(t *testing.T) {
+	p, mr := newTestProcessorServing(t, respondWith(http.StatusCreated))
+	p.SetSuccessStatuses([]int{http.StatusOK}, []int{http.StatusOK})
+
+	processed, err := p.ProcessTask(context.Background(), testTask("created"))
+	if !errors.Is(err, ErrUnexpectedSuccess) || !errors.Is(err, ErrPermanent) {
+		t.Fatalf("ProcessTask error = %v, want ErrUnexpectedSuccess and ErrPermanent", err)
+	}
+	if processed != nil {
+		t.Errorf("ProcessTask returned %+v along with an error, want no task", processed)
+	}
+	if mr.Exists(p.getPaymentKey("created")) {
+		t.Error("payment saved for a status outside the success list")
+	}
+}
+
+func TestProcessTaskConnectionRefused(t *testing.T) {
+	url := closedURL(t)
+	t.Setenv("PROCESSOR_DEFAULT_URL", url)
+	t.Setenv("PROCESSOR_FALLBACK_URL", url)
+
+	p, mr := newTestProcessor(t)
+	p.SetUp(true)
+
+	processed, err := p.ProcessTask(context.Background(), testTask("4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"))
+	if !errors.Is(err, ErrProcessorDown) {
+		t.Errorf("error = %v, want ErrProcessorDown", err)
+	}
+	if processed != nil {
+		t.Errorf("ProcessTask returned %+v along with an error, want no task", processed)
+	}
+	if p.IsUp() {
+		t.Error("default is still up after refusing the connection")
+	}
+	if mr.Exists(p.getPaymentKey("4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3")) {
+		t.Error("payment was saved")
+	}
+}
+
+func BenchmarkHTTPClientProtocols(b *testing.B) {
+	for _, h2 := range []bool{true, false} {
+		name := "http1.1"
+		if h2 {
+			name = "http2"
+		}
+		b.Run(name, func(b *testing.B) {
+			server := httptest.NewUnstartedServer(respondWith(http.StatusOK))
+			server.EnableHTTP2 = h2
+			server.StartTLS()
+			b.Cleanup(server.Close)
+
+			client := newHTTPClient()
+			transport := client.Transport.(*http.Transport)
+			transport.TLSClientConfig = &tls.Config{RootCAs: x509.NewCertPool()}
+			transport.TLSClientConfig.RootCAs.AddCert(server.Certificate())
+
+			wantProto := 1
+			if h2 {
+				wantProto = 2
+			}
+			body := []byte(`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9}`)
+
+			// like the workers, many goroutines posting to one processor
+			b.SetParallelism(8)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					res, err := client.Post(server.URL+"/payments", "application/json", bytes.NewReader(body))
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, res.Body)
+					res.Body.Close()
+					if res.ProtoMajor != wantProto {
+						b.Errorf("spoke HTTP/%d, want HTTP/%d", res.ProtoMajor, wantProto)
+						return
+					}
+				}
+			})
+		})
+	}
+}
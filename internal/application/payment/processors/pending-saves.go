@@ -0,0 +1,31 @@
+package payment
+
+import (
+	"context"
+	"time"
+)
+
+// WaitSaved waits until no worker is in the middle of saving a payment, up to
+// timeout. A drained queue alone is not enough, the last payments taken off it
+// may still be on their way to Redis. It reports whether every save finished.
+func (p *PaymentProcessor) WaitSaved(ctx context.Context, timeout time.Duration) bool {
+	if p.pendingSaves.Load() == 0 {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Millisecond * 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return p.pendingSaves.Load() == 0
+		case <-ticker.C:
+			if p.pendingSaves.Load() == 0 {
+				return true
+			}
+		}
+	}
+}
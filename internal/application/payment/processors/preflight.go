@@ -0,0 +1,34 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const preflightTimeout = 2 * time.Second
+
+// PreflightCheck makes sure every processor in the chain answers on its
+// health endpoint, to catch a misconfigured URL before any payment comes in.
+// Only unreachable processors are reported, a failing or rate-limited health
+// endpoint still proves the URL is right.
+func (p *PaymentProcessor) PreflightCheck(ctx context.Context) error {
+	var errs []error
+	for tier, endpoint := range p.chain.Load().endpoints {
+		probeCtx, cancel := context.WithTimeout(ctx, preflightTimeout)
+		_, err := p.probe(probeCtx, endpoint.url)
+		cancel()
+		if errors.Is(err, errProbeUnreachable) {
+			errs = append(errs, fmt.Errorf("%s processor at %q: %w", tierName(tier), endpoint.url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func tierName(tier int) string {
+	if tier < 2 {
+		return processorName(tier == 0)
+	}
+	return fmt.Sprintf("extra fallback %d", tier-1)
+}
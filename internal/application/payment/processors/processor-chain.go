@@ -0,0 +1,175 @@
+package payment
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// tierCooldown is how long a fallback tier is skipped after failing, about
+// one health check cycle.
+const tierCooldown = 5 * time.Second
+
+// processorEndpoint is one tier of the chain. Tier 0 is the default, whose
+// health comes from the health checks; the others are marked down on failure
+// and tried again after tierCooldown.
+type processorEndpoint struct {
+	url string
+	// fee is the share of each payment the processor keeps, fallback tiers
+	// are tried cheapest first
+	fee float64
+	// downUntil is in unix nanos
+	downUntil atomic.Int64
+}
+
+// processorChain holds the processors in the order they are tried: default,
+// fallback, then any extra fallbacks.
+type processorChain struct {
+	endpoints []*processorEndpoint
+	// requestTimeout bounds each payment POST, zero means no bound
+	requestTimeout time.Duration
+}
+
+// loadProcessorChain builds the chain from CONFIG_FILE, when set, and the
+// environment, which wins over the file.
+func loadProcessorChain() (*processorChain, error) {
+	cfg, err := readProcessorConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	urls := []string{
+		envOr("PROCESSOR_DEFAULT_URL", cfg.DefaultURL),
+		envOr("PROCESSOR_FALLBACK_URL", cfg.FallbackURL),
+	}
+	extraURLs := cfg.ExtraFallbackURLs
+	// PROCESSOR_EXTRA_FALLBACK_URLS is a comma-separated list tried after the fallback
+	if env := os.Getenv("PROCESSOR_EXTRA_FALLBACK_URLS"); env != "" {
+		extraURLs = strings.Split(env, ",")
+	}
+	for _, url := range extraURLs {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+
+	fees := []string{
+		envOr("PROCESSOR_DEFAULT_FEE", formatFee(cfg.DefaultFee)),
+		envOr("PROCESSOR_FALLBACK_FEE", formatFee(cfg.FallbackFee)),
+	}
+	// PROCESSOR_EXTRA_FALLBACK_FEES is a comma-separated list lining up with
+	// the extra fallback URLs
+	if env := os.Getenv("PROCESSOR_EXTRA_FALLBACK_FEES"); env != "" {
+		fees = append(fees, strings.Split(env, ",")...)
+	} else {
+		for _, fee := range cfg.ExtraFallbackFees {
+			fees = append(fees, formatFee(fee))
+		}
+	}
+
+	requestTimeout, err := parseRequestTimeout(envOr("PROCESSOR_REQUEST_TIMEOUT", cfg.RequestTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &processorChain{
+		endpoints:      make([]*processorEndpoint, len(urls)),
+		requestTimeout: requestTimeout,
+	}
+	for i, url := range urls {
+		fee, err := parseFee(fees, i)
+		if err != nil {
+			return nil, err
+		}
+		chain.endpoints[i] = &processorEndpoint{url: url, fee: fee}
+	}
+	return chain, nil
+}
+
+func formatFee(fee float64) string {
+	return strconv.FormatFloat(fee, 'f', -1, 64)
+}
+
+// parseFee reads the fee of tier i, zero when it isn't set.
+func parseFee(fees []string, i int) (float64, error) {
+	if i >= len(fees) || strings.TrimSpace(fees[i]) == "" {
+		return 0, nil
+	}
+	fee, err := strconv.ParseFloat(strings.TrimSpace(fees[i]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("error on parsing processor fee: %w", err)
+	}
+	if fee < 0 {
+		return 0, fmt.Errorf("error on parsing processor fee: %g is negative", fee)
+	}
+	return fee, nil
+}
+
+// ReloadConfig re-reads the processor URLs from CONFIG_FILE and the
+// environment. Requests already in flight finish against the old ones. A
+// broken config file keeps the current setup.
+func (p *PaymentProcessor) ReloadConfig() {
+	chain, err := loadProcessorChain()
+	if err != nil {
+		fmt.Println("failed to reload processor config:", err)
+		return
+	}
+	p.chain.Store(chain)
+
+	urls := make([]string, len(chain.endpoints))
+	for i, endpoint := range chain.endpoints {
+		urls[i] = endpoint.url
+	}
+	fmt.Printf("processor urls reloaded: %s\n", strings.Join(urls, ", "))
+}
+
+// pickTier returns the tier a payment goes to: the default when onDefault,
+// otherwise the cheapest fallback tier not cooling down, the earliest one on
+// equal fees. When every one is cooling down, the first fallback is used
+// anyway.
+func (p *PaymentProcessor) pickTier(onDefault bool) int {
+	if onDefault {
+		return 0
+	}
+
+	chain := p.chain.Load()
+	now := time.Now().UnixNano()
+	best := 0
+	for tier := 1; tier < len(chain.endpoints); tier++ {
+		endpoint := chain.endpoints[tier]
+		if now < endpoint.downUntil.Load() {
+			continue
+		}
+		if best == 0 || endpoint.fee < chain.endpoints[best].fee {
+			best = tier
+		}
+	}
+	if best == 0 {
+		return 1
+	}
+	return best
+}
+
+// markTierDown skips a fallback tier for tierCooldown. The default tier is
+// left to the health checks.
+func (p *PaymentProcessor) markTierDown(tier int) {
+	chain := p.chain.Load()
+	if tier < 1 || tier >= len(chain.endpoints) {
+		return
+	}
+	chain.endpoints[tier].downUntil.Store(time.Now().Add(tierCooldown).UnixNano())
+}
+
+func (p *PaymentProcessor) tierURL(tier int) string {
+	return p.chain.Load().endpoints[tier].url
+}
+
+func (p *PaymentProcessor) processorURL(onDefault bool) string {
+	if onDefault {
+		return p.tierURL(0)
+	}
+	return p.tierURL(1)
+}
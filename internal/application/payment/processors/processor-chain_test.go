@@ -0,0 +1,51 @@
+package payment
+
+import "testing"
+
+func TestPickTierPrefersCheapestFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		extraFees string
+		down      []int
+		want      int
+	}{
+		{name: "no fees keeps the chain order", want: 1},
+		{name: "cheaper extra fallback", extraFees: "0.03,0.04", want: 2},
+		{name: "equal fees keep the chain order", extraFees: "0.05,0.05", want: 1},
+		{name: "cheapest cooling down", extraFees: "0.03,0.04", down: []int{2}, want: 3},
+		{name: "every fallback cooling down", extraFees: "0.03,0.04", down: []int{1, 2, 3}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROCESSOR_DEFAULT_URL", "http://default")
+			t.Setenv("PROCESSOR_FALLBACK_URL", "http://fallback")
+			t.Setenv("PROCESSOR_EXTRA_FALLBACK_URLS", "http://fallback2,http://fallback3")
+			t.Setenv("PROCESSOR_DEFAULT_FEE", "0.01")
+			if tt.extraFees != "" {
+				t.Setenv("PROCESSOR_FALLBACK_FEE", "0.05")
+			}
+			t.Setenv("PROCESSOR_EXTRA_FALLBACK_FEES", tt.extraFees)
+
+			p, _ := newTestProcessor(t)
+			for _, tier := range tt.down {
+				p.markTierDown(tier)
+			}
+
+			if got := p.pickTier(false); got != tt.want {
+				t.Errorf("pickTier(false) = %d, want %d", got, tt.want)
+			}
+			if got := p.pickTier(true); got != 0 {
+				t.Errorf("pickTier(true) = %d, want the default", got)
+			}
+		})
+	}
+}
+
+func TestLoadProcessorChainRejectsBadFees(t *testing.T) {
+	for _, fee := range []string{"abc", "-0.01"} {
+		t.Setenv("PROCESSOR_FALLBACK_FEE", fee)
+		if _, err := loadProcessorChain(); err == nil {
+			t.Errorf("fee %q loaded, want an error", fee)
+		}
+	}
+}
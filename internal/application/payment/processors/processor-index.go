@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/redis/go-redis/v9"
+)
+
+// EnableProcessorIndexes keeps a by-date index and running count/amount per
+// processor next to the combined index, so summaries covering every stored
+// payment skip the full scan.
+func (p *PaymentProcessor) EnableProcessorIndexes() {
+	p.processorIndexes = true
+}
+
+func processorName(onDefault bool) string {
+	if onDefault {
+		return tasks.ProcessorDefault
+	}
+	return tasks.ProcessorFallback
+}
+
+// processorIndexChange is what indexing one saved payment did to the
+// per-processor indexes, readable once its pipeline ran.
+type processorIndexChange struct {
+	onDefault bool
+	amount    float64
+	added     *redis.IntCmd
+	moved     *redis.IntCmd
+}
+
+// indexProcessorPayment queues the per-processor index updates for a saved
+// payment on pipe. It is added to its processor's index and removed from the
+// other one, in case an earlier save of it went there. The totals are left to
+// settleProcessorTotals, once pipe ran and the changes are known.
+func (p *PaymentProcessor) indexProcessorPayment(ctx context.Context, pipe redis.Pipeliner, k string, at int64, onDefault bool, amount float64) (processorIndexChange, bool) {
+	if !p.processorIndexes {
+		return processorIndexChange{}, false
+	}
+
+	return processorIndexChange{
+		onDefault: onDefault,
+		amount:    amount,
+		added: p.zAddIndex(ctx, pipe, p.getProcessorIndexKey(processorName(onDefault)), redis.Z{
+			Score:  float64(at),
+			Member: k,
+		}),
+		moved: pipe.ZRem(ctx, p.getProcessorIndexKey(processorName(!onDefault)), k),
+	}, true
+}
+
+// settleProcessorTotals moves the running totals by what indexing actually
+// changed: a payment counts once for the processor it was newly indexed
+// under, and stops counting for the one it moved away from. Saving it again
+// changes nothing. The payments are already stored, so a failure is only
+// logged; processorTotals sees the counts drift and scans instead.
+func (p *PaymentProcessor) settleProcessorTotals(ctx context.Context, changes []processorIndexChange) {
+	pipe := p.cache.TxPipeline()
+	for _, c := range changes {
+		added, moved := c.added.Val() == 1, c.moved.Val() == 1
+		if p.totals != nil {
+			p.countProcessorPayment(c.onDefault, c.amount, added, moved)
+			continue
+		}
+		if added {
+			p.queueTotalsChange(ctx, pipe, processorName(c.onDefault), 1, c.amount)
+		}
+		if moved {
+			p.queueTotalsChange(ctx, pipe, processorName(!c.onDefault), -1, -c.amount)
+		}
+	}
+	if pipe.Len() == 0 {
+		return
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Println("failed to update processor totals:", err)
+	}
+}
+
+// countProcessorPayment buffers the running totals changes of a saved
+// payment, when they are flushed periodically.
+func (p *PaymentProcessor) countProcessorPayment(onDefault bool, amount float64, added, moved bool) {
+	if !p.processorIndexes || p.totals == nil {
+		return
+	}
+	if added {
+		p.totals.add(processorName(onDefault), 1, amount)
+	}
+	if moved {
+		p.totals.add(processorName(!onDefault), -1, -amount)
+	}
+}
+
+func (p *PaymentProcessor) queueTotalsChange(ctx context.Context, pipe redis.Pipeliner, processor string, count int64, amount float64) {
+	pipe.HIncrBy(ctx, p.getProcessorTotalsKey(), processor+":count", count)
+	pipe.HIncrByFloat(ctx, p.getProcessorTotalsKey(), processor+":amount", amount)
+}
+
+// unindexProcessorPayments queues removal of compacted payments from the
+// per-processor indexes and totals.
+func (p *PaymentProcessor) unindexProcessorPayments(ctx context.Context, pipe redis.Pipeliner, keys []string, records []any) {
+	if !p.processorIndexes {
+		return
+	}
+
+	members := make([]any, len(keys))
+	for i, k := range keys {
+		members[i] = k
+	}
+	for _, processor := range []string{tasks.ProcessorDefault, tasks.ProcessorFallback} {
+		pipe.ZRem(ctx, p.getProcessorIndexKey(processor), members...)
+	}
+
+	for _, result := range records {
+		if result == nil {
+			continue
+		}
+		payment := models.PaymentRecord{}
+		if err := p.codec.Unmarshal([]byte(result.(string)), &payment); err != nil || payment.DryRun {
+			continue
+		}
+		p.queueTotalsChange(ctx, pipe, processorName(payment.OnDefault), -1, -payment.Amount)
+	}
+}
+
+// processorTotals answers the summary from the running totals when the window
+// holds every payment of both processors and the totals agree with the
+// indexes. ok is false whenever a full scan is needed instead.
+func (p *PaymentProcessor) processorTotals(ctx context.Context, from, to int64) (res *models.PaymentsSummaryResponse, ok bool, err error) {
+	if !p.processorIndexes {
+		return nil, false, nil
+	}
+
+	minScore, maxScore := strconv.FormatInt(from, 10), strconv.FormatInt(to, 10)
+	pipe := p.cache.Pipeline()
+	defaultInWindow := pipe.ZCount(ctx, p.getProcessorIndexKey(tasks.ProcessorDefault), minScore, maxScore)
+	defaultTotal := pipe.ZCard(ctx, p.getProcessorIndexKey(tasks.ProcessorDefault))
+	fallbackInWindow := pipe.ZCount(ctx, p.getProcessorIndexKey(tasks.ProcessorFallback), minScore, maxScore)
+	fallbackTotal := pipe.ZCard(ctx, p.getProcessorIndexKey(tasks.ProcessorFallback))
+	allInWindow := pipe.ZCount(ctx, p.getPaymentsIndexKey(), minScore, maxScore)
+	totals := pipe.HGetAll(ctx, p.getProcessorTotalsKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, fmt.Errorf("error on getting processor totals: %w", err)
+	}
+
+	if defaultInWindow.Val() != defaultTotal.Val() || fallbackInWindow.Val() != fallbackTotal.Val() {
+		return nil, false, nil
+	}
+	// payments saved before the indexes were enabled, and dry-run ones, only
+	// live in the combined one
+	if allInWindow.Val() != defaultTotal.Val()+fallbackTotal.Val() {
+		return nil, false, nil
+	}
+
+	values := totals.Val()
+	defaultSummary, ok := summaryFromTotals(values, tasks.ProcessorDefault, defaultTotal.Val())
+	if !ok {
+		return nil, false, nil
+	}
+	fallbackSummary, ok := summaryFromTotals(values, tasks.ProcessorFallback, fallbackTotal.Val())
+	if !ok {
+		return nil, false, nil
+	}
+
+	return &models.PaymentsSummaryResponse{
+		Default:  defaultSummary,
+		Fallback: fallbackSummary,
+	}, true, nil
+}
+
+// summaryFromTotals reads a processor's running totals, refusing them when the
+// count drifted from the index (duplicates, partial compaction).
+func summaryFromTotals(values map[string]string, processor string, indexed int64) (models.PaymentsSummary, bool) {
+	summary := models.PaymentsSummary{}
+
+	count, _ := strconv.ParseInt(values[processor+":count"], 10, 64)
+	if count != indexed {
+		return summary, false
+	}
+	amount, _ := strconv.ParseFloat(values[processor+":amount"], 64)
+
+	summary.TotalRequests = int(count)
+	summary.TotalAmount = amount
+	return summary, true
+}
+
+func (p *PaymentProcessor) getProcessorIndexKey(processor string) string {
+	return p.prefixKey("payments:" + processor + ":by-date")
+}
+
+func (p *PaymentProcessor) getProcessorTotalsKey() string {
+	return p.prefixKey("payments:totals")
+}
@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProcessorTotalsCountResavesOnce(t *testing.T) {
+	for _, mode := range []string{SaveModeLua, SaveModeTx, SaveModePipeline} {
+		for _, buffered := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s buffered %t", mode, buffered), func(t *testing.T) {
+				p, _ := newTestProcessor(t)
+				p.EnableProcessorIndexes()
+				if err := p.SetSaveMode(mode); err != nil {
+					t.Fatal(err)
+				}
+				if buffered {
+					p.SetTotalsFlushInterval(time.Hour)
+					t.Cleanup(p.Close)
+				}
+				ctx := context.Background()
+
+				save := func(onDefault bool) {
+					t.Helper()
+					record := testRecord(1)
+					record.OnDefault = onDefault
+					if err := p.store.Save(ctx, testEpoch, record); err != nil {
+						t.Fatal(err)
+					}
+					if buffered {
+						p.totals.flush()
+					}
+				}
+				check := func(wantDefault, wantFallback int) {
+					t.Helper()
+					res, ok, err := p.processorTotals(ctx, testEpoch, testEpoch+1)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !ok {
+						t.Fatal("totals disagree with the indexes")
+					}
+					if res.Default.TotalRequests != wantDefault || res.Fallback.TotalRequests != wantFallback {
+						t.Errorf("totals = %d default, %d fallback, want %d and %d",
+							res.Default.TotalRequests, res.Fallback.TotalRequests, wantDefault, wantFallback)
+					}
+					if want := 19.9 * float64(wantDefault); roundAmount(res.Default.TotalAmount, 2) != want {
+						t.Errorf("default amount = %v, want %v", res.Default.TotalAmount, want)
+					}
+					if want := 19.9 * float64(wantFallback); roundAmount(res.Fallback.TotalAmount, 2) != want {
+						t.Errorf("fallback amount = %v, want %v", res.Fallback.TotalAmount, want)
+					}
+				}
+
+				save(true)
+				save(true)
+				check(1, 0)
+
+				// processed again, by the fallback this time
+				save(false)
+				check(0, 1)
+			})
+		}
+	}
+}
+
+func BenchmarkSummaryProcessorTotals(b *testing.B) {
+	const payments = 10_000
+	for _, indexes := range []bool{false, true} {
+		b.Run(fmt.Sprintf("indexes %t", indexes), func(b *testing.B) {
+			p, _ := newTestProcessor(b)
+			if indexes {
+				p.EnableProcessorIndexes()
+			}
+			saveTestRecords(b, p, payments)
+
+			ctx := context.Background()
+			b.ReportAllocs()
+			for b.Loop() {
+				res, err := p.summaryPayments(ctx, testEpoch, testEpoch+payments, SummaryOptions{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if got := res.Default.TotalRequests + res.Fallback.TotalRequests; got != payments {
+					b.Fatalf("summarized %d payments, want %d", got, payments)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package payment
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+)
+
+// encodeQueuedTask prefixes a raw task with when it was first accepted, in
+// unix millis, so a task coming back from Redis keeps its age.
+func encodeQueuedTask(item queue.Item) []byte {
+	value := strconv.AppendInt(nil, item.EnqueuedAt.UnixMilli(), 10)
+	value = append(value, ' ')
+	return append(value, item.Data...)
+}
+
+// decodeQueuedTask reverses encodeQueuedTask. A task stored without the
+// prefix, by an older instance, counts as accepted now.
+func decodeQueuedTask(value string) queue.Item {
+	if at, task, ok := strings.Cut(value, " "); ok {
+		if millis, err := strconv.ParseInt(at, 10, 64); err == nil {
+			return queue.Item{Data: []byte(task), EnqueuedAt: time.UnixMilli(millis)}
+		}
+	}
+	return queue.Item{Data: []byte(value), EnqueuedAt: time.Now()}
+}
+
+func encodeQueuedTasks(items []queue.Item) []any {
+	values := make([]any, len(items))
+	for i, item := range items {
+		values[i] = encodeQueuedTask(item)
+	}
+	return values
+}
+
+func decodeQueuedTasks(values []string) []queue.Item {
+	items := make([]queue.Item, len(values))
+	for i, v := range values {
+		items[i] = decodeQueuedTask(v)
+	}
+	return items
+}
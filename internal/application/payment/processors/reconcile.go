@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+// Reconcile compares our summary for the window against what both processors
+// report on their admin summary endpoints.
+func (p *PaymentProcessor) Reconcile(ctx context.Context, from, to time.Time, adminToken string) (*models.ReconcileResult, error) {
+	local, err := p.SummaryPayments(ctx, from.UnixMilli(), to.UnixMilli(), SummaryOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	res := models.ReconcileResult{
+		Local: *local,
+	}
+	res.Remote.Default, err = p.remoteSummary(ctx, p.processorURL(true), from, to, adminToken)
+	if err != nil {
+		return nil, err
+	}
+	res.Remote.Fallback, err = p.remoteSummary(ctx, p.processorURL(false), from, to, adminToken)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Delta.Default = models.PaymentsSummary{
+		TotalRequests: res.Local.Default.TotalRequests - res.Remote.Default.TotalRequests,
+		TotalAmount:   roundAmount(res.Local.Default.TotalAmount-res.Remote.Default.TotalAmount, p.precision),
+	}
+	res.Delta.Fallback = models.PaymentsSummary{
+		TotalRequests: res.Local.Fallback.TotalRequests - res.Remote.Fallback.TotalRequests,
+		TotalAmount:   roundAmount(res.Local.Fallback.TotalAmount-res.Remote.Fallback.TotalAmount, p.precision),
+	}
+	return &res, nil
+}
+
+func (p *PaymentProcessor) remoteSummary(ctx context.Context, baseURL string, from, to time.Time, adminToken string) (models.PaymentsSummary, error) {
+	summary := models.PaymentsSummary{}
+
+	q := url.Values{}
+	q.Set("from", from.UTC().Format(time.RFC3339Nano))
+	q.Set("to", to.UTC().Format(time.RFC3339Nano))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/admin/payments-summary?"+q.Encode(), nil)
+	if err != nil {
+		return summary, fmt.Errorf("error on building processor summary request: %w", err)
+	}
+	req.Header.Set("X-Rinha-Token", adminToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return summary, fmt.Errorf("error on getting processor summary: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return summary, fmt.Errorf("processor summary status: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return summary, fmt.Errorf("error on decoding processor summary: %w", err)
+	}
+	return summary, nil
+}
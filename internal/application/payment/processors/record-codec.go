@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"bytes"
+	"fmt"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/json"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// RecordCodecJSON stores records as JSON, readable with redis-cli.
+	RecordCodecJSON = "json"
+	// RecordCodecMsgpack stores records as msgpack, about 10% smaller than
+	// JSON, which shrinks Redis memory and what the summary MGETs.
+	RecordCodecMsgpack = "msgpack"
+)
+
+// RecordCodec encodes the payment records kept in Redis. The API keeps
+// speaking JSON whatever codec is used.
+type RecordCodec interface {
+	Marshal(record models.PaymentRecord) ([]byte, error)
+	Unmarshal(data []byte, record *models.PaymentRecord) error
+}
+
+func (p *PaymentProcessor) SetRecordCodec(name string) error {
+	switch name {
+	case RecordCodecJSON:
+		p.codec = jsonCodec{}
+	case RecordCodecMsgpack:
+		p.codec = msgpackCodec{}
+	default:
+		return fmt.Errorf("unknown record codec %q", name)
+	}
+	return nil
+}
+
+// recordJSON returns a stored record as JSON, re-encoding it only when it
+// was stored in another format.
+func (p *PaymentProcessor) recordJSON(data []byte) ([]byte, error) {
+	if isJSONRecord(data) {
+		return data, nil
+	}
+
+	record := models.PaymentRecord{}
+	if err := p.codec.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("error on decoding payment record: %w", err)
+	}
+	return json.Marshal(record)
+}
+
+func isJSONRecord(data []byte) bool {
+	return len(data) > 0 && data[0] == '{'
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(record models.PaymentRecord) ([]byte, error) {
+	return json.Marshal(record)
+}
+
+func (jsonCodec) Unmarshal(data []byte, record *models.PaymentRecord) error {
+	return json.Unmarshal(data, record)
+}
+
+// msgpackCodec reuses the json tags so both formats carry the same fields.
+// Records still in JSON from before the switch are read as JSON.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(record models.PaymentRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(record); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, record *models.PaymentRecord) error {
+	if isJSONRecord(data) {
+		return json.Unmarshal(data, record)
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(record)
+}
@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"reflect"
+	"testing"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+var testCodecs = []struct {
+	name  string
+	codec RecordCodec
+}{
+	{name: RecordCodecJSON, codec: jsonCodec{}},
+	{name: RecordCodecMsgpack, codec: msgpackCodec{}},
+}
+
+func TestRecordCodecsRoundTrip(t *testing.T) {
+	record := testRecord(1)
+	record.DurationMs = 12.5
+	for _, tc := range testCodecs {
+		data, err := tc.codec.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := models.PaymentRecord{}
+		if err := tc.codec.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, record) {
+			t.Errorf("%s: decoded %+v, want %+v", tc.name, got, record)
+		}
+	}
+}
+
+func BenchmarkRecordCodecs(b *testing.B) {
+	record := testRecord(1)
+	for _, tc := range testCodecs {
+		data, err := tc.codec.Marshal(record)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(tc.name+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := tc.codec.Marshal(record); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(data)), "bytes/record")
+		})
+		b.Run(tc.name+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				decoded := models.PaymentRecord{}
+				if err := tc.codec.Unmarshal(data, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(data)), "bytes/record")
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package payment
+
+import (
+	"context"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+// RedisHealth pings Redis and reports the round trip alongside the pool stats.
+func (p *PaymentProcessor) RedisHealth(ctx context.Context) models.RedisHealth {
+	health := models.RedisHealth{
+		Available: p.IsRedisUp(),
+	}
+
+	startedAt := time.Now()
+	err := p.cache.Ping(ctx).Err()
+	health.PingMs = float64(time.Since(startedAt).Microseconds()) / 1000
+	if err != nil {
+		health.Error = err.Error()
+	}
+
+	stats := p.cache.PoolStats()
+	health.Pool = models.RedisPoolStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+	}
+	return health
+}
@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const redisPingTimeout = 500 * time.Millisecond
+
+func (p *PaymentProcessor) IsRedisUp() bool {
+	p.redisMutex.RLock()
+	defer p.redisMutex.RUnlock()
+	return p.redisUp
+}
+
+// WaitRedis blocks until Redis is reachable or ctx is done. A reachable
+// Redis always wins, even once ctx is done.
+func (p *PaymentProcessor) WaitRedis(ctx context.Context) error {
+	p.redisMutex.RLock()
+	redisSignal := p.redisSignal
+	p.redisMutex.RUnlock()
+
+	// select picks randomly among ready cases, so check the signal alone first
+	select {
+	case <-redisSignal:
+		return nil
+	default:
+	}
+	select {
+	case <-redisSignal:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CheckRedis pings Redis and updates the availability gate the workers wait on.
+func (p *PaymentProcessor) CheckRedis(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, redisPingTimeout)
+	defer cancel()
+
+	err := p.cache.Ping(ctx).Err()
+	if err != nil {
+		fmt.Println("redis is unavailable:", err)
+	}
+	p.setRedisUp(err == nil)
+}
+
+func (p *PaymentProcessor) setRedisUp(status bool) {
+	p.redisMutex.Lock()
+	defer p.redisMutex.Unlock()
+
+	if status == p.redisUp {
+		return
+	}
+	p.redisUp = status
+	if status {
+		fmt.Println("redis is back")
+		close(p.redisSignal)
+		return
+	}
+	p.redisSignal = make(chan struct{})
+}
@@ -0,0 +1,28 @@
+package payment
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWaitPrefersSignalOverDoneContext(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	p.SetUp(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// select alone would pick either ready case about half the time
+	for range 100 {
+		if err := p.WaitUp(ctx); err != nil {
+			t.Fatalf("WaitUp with the processor up = %v, want nil", err)
+		}
+		if err := p.WaitRedis(ctx); err != nil {
+			t.Fatalf("WaitRedis with redis up = %v, want nil", err)
+		}
+	}
+
+	p.SetUp(false)
+	if err := p.WaitUp(ctx); err == nil {
+		t.Error("WaitUp with the processor down and ctx done = nil, want an error")
+	}
+}
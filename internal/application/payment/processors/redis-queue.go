@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PushQueue appends a raw task to the Redis-backed queue.
+func (p *PaymentProcessor) PushQueue(ctx context.Context, tasks ...[]byte) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	values := make([]any, len(tasks))
+	for i, task := range tasks {
+		values[i] = task
+	}
+	if err := p.cache.RPush(ctx, p.getQueueKey(), values...).Err(); err != nil {
+		return fmt.Errorf("error on pushing queued tasks: %w", err)
+	}
+	return nil
+}
+
+// PopQueue takes up to n tasks off the head of the Redis-backed queue in a
+// single round trip, waiting up to timeout for at least one to show up.
+func (p *PaymentProcessor) PopQueue(ctx context.Context, n int, timeout time.Duration) ([][]byte, error) {
+	_, values, err := p.cache.BLMPop(ctx, timeout, "left", int64(n), p.getQueueKey()).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error on popping queued tasks: %w", err)
+	}
+
+	tasks := make([][]byte, len(values))
+	for i, v := range values {
+		tasks[i] = []byte(v)
+	}
+	return tasks, nil
+}
+
+// RetryQueuedLater holds each task back from the Redis-backed queue for its
+// delay, after which PromoteQueuedRetries moves it to the tail.
+func (p *PaymentProcessor) RetryQueuedLater(ctx context.Context, tasks [][]byte, delays []time.Duration) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	now := p.now()
+	members := make([]redis.Z, len(tasks))
+	for i, task := range tasks {
+		members[i] = redis.Z{
+			Score:  float64(now.Add(delays[i]).UnixMilli()),
+			Member: task,
+		}
+	}
+	if err := p.cache.ZAdd(ctx, p.getQueueRetryKey(), members...).Err(); err != nil {
+		return fmt.Errorf("error on scheduling queued task retries: %w", err)
+	}
+	return nil
+}
+
+// promoteRetriesScript moves up to ARGV[2] tasks due by ARGV[1] from the retry
+// set to the queue at once, so no other instance sees them in both or neither.
+//
+// KEYS: retry set, queue
+var promoteRetriesScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #due == 0 then
+	return 0
+end
+redis.call('RPUSH', KEYS[2], unpack(due))
+redis.call('ZREM', KEYS[1], unpack(due))
+return #due
+`)
+
+// PromoteQueuedRetries moves up to n tasks whose retry time has come back to
+// the queue, returning how many were moved.
+func (p *PaymentProcessor) PromoteQueuedRetries(ctx context.Context, n int) (int, error) {
+	keys := []string{p.getQueueRetryKey(), p.getQueueKey()}
+	moved, err := promoteRetriesScript.Run(ctx, p.cache, keys, p.now().UnixMilli(), n).Int()
+	if err != nil {
+		return 0, fmt.Errorf("error on promoting queued task retries: %w", err)
+	}
+	return moved, nil
+}
+
+func (p *PaymentProcessor) getQueueKey() string {
+	return p.prefixKey("payments:queue")
+}
+
+func (p *PaymentProcessor) getQueueRetryKey() string {
+	return p.prefixKey("payments:queue:retry")
+}
@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// RoutingImmediate sends everything to the default as soon as it is up.
+	RoutingImmediate = "immediate"
+	// RoutingRamp grows the default's share linearly over the ramp window
+	// after a recovery, keeping the rest on the fallback.
+	RoutingRamp = "ramp"
+)
+
+func (p *PaymentProcessor) SetRoutingPolicy(policy string, rampWindow time.Duration) error {
+	switch policy {
+	case RoutingImmediate, RoutingRamp:
+	default:
+		return fmt.Errorf("unknown routing policy %q", policy)
+	}
+
+	p.upMutex.Lock()
+	defer p.upMutex.Unlock()
+	p.routingPolicy = policy
+	p.rampWindow = rampWindow
+	return nil
+}
+
+// DisableFallback makes every request go to the default processor, even
+// while it is down, so failures are retried instead of rerouted.
+func (p *PaymentProcessor) DisableFallback() {
+	p.upMutex.Lock()
+	defer p.upMutex.Unlock()
+	p.fallbackDisabled = true
+}
+
+// useDefault decides, per request, whether to hit the default processor.
+func (p *PaymentProcessor) useDefault() bool {
+	p.upMutex.RLock()
+	defer p.upMutex.RUnlock()
+
+	if p.fallbackDisabled {
+		return true
+	}
+	if !p.up {
+		return false
+	}
+	if p.routingPolicy != RoutingRamp || p.rampWindow <= 0 {
+		return true
+	}
+
+	elapsed := time.Since(p.recoveredAt)
+	if elapsed >= p.rampWindow {
+		return true
+	}
+	return rand.Float64() < float64(elapsed)/float64(p.rampWindow)
+}
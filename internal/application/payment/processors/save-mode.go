@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// SaveModeLua saves through savePaymentScript: the record and its index
+	// entry are stored together or not at all. One EVALSHA per payment.
+	SaveModeLua = "lua"
+	// SaveModeTx wraps the writes in MULTI/EXEC. Nothing interleaves with them,
+	// but a command failing at runtime doesn't undo the others, so a record can
+	// end up without its index entry and be missed by the summary.
+	SaveModeTx = "tx"
+	// SaveModePipeline sends the same writes without MULTI/EXEC. Fastest, but
+	// on top of the tx caveat other clients' commands can run between them, so
+	// a summary may read the index and version before the payment is complete.
+	SaveModePipeline = "pipeline"
+)
+
+func (p *PaymentProcessor) SetSaveMode(mode string) error {
+	switch mode {
+	case SaveModeLua, SaveModeTx, SaveModePipeline:
+	default:
+		return fmt.Errorf("unknown save mode %q", mode)
+	}
+	p.saveMode = mode
+	return nil
+}
+
+func (p *PaymentProcessor) runSavePaymentPipeline(ctx context.Context, k string, record []byte, at int64, onDefault bool, amount float64, index bool) error {
+	var pipe redis.Pipeliner
+	if p.saveMode == SaveModeTx {
+		pipe = p.cache.TxPipeline()
+	} else {
+		pipe = p.cache.Pipeline()
+	}
+
+	pipe.Set(ctx, k, record, 0)
+	p.zAddIndex(ctx, pipe, p.getPaymentsIndexKey(), redis.Z{
+		Score:  float64(at),
+		Member: k,
+	})
+	var change processorIndexChange
+	if index {
+		change, index = p.indexProcessorPayment(ctx, pipe, k, at, onDefault, amount)
+	}
+	pipe.Incr(ctx, p.getPaymentsVersionKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error on saving processed payments: %w", err)
+	}
+	if index {
+		p.settleProcessorTotals(ctx, []processorIndexChange{change})
+	}
+	return nil
+}
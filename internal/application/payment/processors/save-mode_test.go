@@ -0,0 +1,37 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// BenchmarkSaveModes compares the round trips and client cost of each save
+// mode. miniredis runs scripts in an embedded Lua interpreter, far slower than
+// Redis' own, so the lua ns/op overstates what the script costs in production.
+func BenchmarkSaveModes(b *testing.B) {
+	for _, mode := range []string{SaveModeTx, SaveModePipeline, SaveModeLua} {
+		for _, indexes := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s indexes %t", mode, indexes), func(b *testing.B) {
+				p, mr := newTestProcessor(b)
+				if err := p.SetSaveMode(mode); err != nil {
+					b.Fatal(err)
+				}
+				if indexes {
+					p.EnableProcessorIndexes()
+				}
+
+				ctx := context.Background()
+				commands := mr.CommandCount()
+				b.ReportAllocs()
+				i := 0
+				for ; b.Loop(); i++ {
+					if err := p.store.Save(ctx, testEpoch+int64(i), testRecord(i)); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(mr.CommandCount()-commands)/float64(i), "redis-cmds/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+// SetSaveRetries retries a failed store write up to retries more times,
+// waiting backoff, doubled each time, in between. By then the processor has
+// already taken the payment, so a short Redis hiccup must not lose it. Zero
+// disables the retries.
+func (p *PaymentProcessor) SetSaveRetries(retries int, backoff time.Duration) {
+	p.saveRetries = retries
+	p.saveRetryBackoff = backoff
+}
+
+func (p *PaymentProcessor) saveRecord(ctx context.Context, at int64, record models.PaymentRecord) error {
+	backoff := p.saveRetryBackoff
+	for try := 0; ; try++ {
+		err := p.store.Save(ctx, at, record)
+		if err == nil || try >= p.saveRetries {
+			return err
+		}
+		fmt.Printf("failed to save payment %s, retrying in %s: %v\n", record.CorrelationId, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,48 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSaveRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		wantErr   error
+		wantSaved bool
+	}{
+		{name: "redis recovers in time", retries: 2, wantSaved: true},
+		// charged but not saved, which workers dead-letter for reconciling
+		{name: "retries exhausted", retries: 1, wantErr: ErrNotRecorded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts atomic.Int64
+			p, mr := newTestProcessorServing(t, func(w http.ResponseWriter, r *http.Request) {
+				posts.Add(1)
+				w.WriteHeader(http.StatusOK)
+			})
+			p.SetSaveRetries(tt.retries, time.Millisecond)
+			failNext(p, "evalsha", 2)
+
+			_, err := p.ProcessTask(context.Background(), testTask("4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, ErrPermanent) {
+				t.Errorf("error = %v, want it permanent so it is never retried", err)
+			}
+			if posts.Load() != 1 {
+				t.Errorf("payment posted %d times, want once", posts.Load())
+			}
+			if saved := mr.Exists(p.getPaymentKey("4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3")); saved != tt.wantSaved {
+				t.Errorf("saved = %t, want %t", saved, tt.wantSaved)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// savePaymentScript writes the record and its index entry as one unit: keys
+// of the wrong type are refused before writing anything and a failing SET
+// undoes the ZADD, so a record is never stored without being visible to the
+// summary or the other way round. The per-processor index and totals are only
+// touched once both went through, and the totals only count payments newly
+// indexed under a processor, or moved away from the other one.
+// Returns whether the payment was added to its processor's index and whether
+// it was removed from the other's.
+//
+// KEYS: record, index, version, processor index, processor totals, other
+// processor index
+// ARGV: record JSON, score, processor, amount, "1" to update processor indexes,
+// "1" to update the processor totals too, "1" to keep the earliest index
+// score, other processor
+var savePaymentScript = redis.NewScript(`
+local function zadd(call, key)
+	if ARGV[7] == '1' then
+		return call('ZADD', key, 'LT', ARGV[2], KEYS[1])
+	end
+	return call('ZADD', key, ARGV[2], KEYS[1])
+end
+
+-- a key holding another type would fail its write halfway, so refuse up front
+local function wrongType(key, want)
+	local t = redis.call('TYPE', key).ok
+	return t ~= 'none' and t ~= want
+end
+if wrongType(KEYS[1], 'string') or wrongType(KEYS[2], 'zset') or
+	(ARGV[5] == '1' and (wrongType(KEYS[4], 'zset') or wrongType(KEYS[6], 'zset') or wrongType(KEYS[5], 'hash'))) then
+	return redis.error_reply('WRONGTYPE Operation against a key holding the wrong kind of value')
+end
+
+local added = zadd(redis.pcall, KEYS[2])
+if type(added) == 'table' and added.err then
+	return added
+end
+
+local saved = redis.pcall('SET', KEYS[1], ARGV[1])
+if type(saved) == 'table' and saved.err then
+	if added == 1 then
+		redis.call('ZREM', KEYS[2], KEYS[1])
+	end
+	return saved
+end
+
+local indexed, moved = 0, 0
+if ARGV[5] == '1' then
+	indexed = zadd(redis.call, KEYS[4])
+	moved = redis.call('ZREM', KEYS[6], KEYS[1])
+	if ARGV[6] == '1' then
+		if indexed == 1 then
+			redis.call('HINCRBY', KEYS[5], ARGV[3] .. ':count', 1)
+			redis.call('HINCRBYFLOAT', KEYS[5], ARGV[3] .. ':amount', ARGV[4])
+		end
+		if moved == 1 then
+			redis.call('HINCRBY', KEYS[5], ARGV[8] .. ':count', -1)
+			redis.call('HINCRBYFLOAT', KEYS[5], ARGV[8] .. ':amount', '-' .. ARGV[4])
+		end
+	end
+end
+
+redis.call('INCR', KEYS[3])
+return {indexed, moved}
+`)
+
+func (p *PaymentProcessor) runSavePaymentScript(ctx context.Context, k string, record []byte, at int64, onDefault bool, amount float64, index bool) error {
+	processor, other := processorName(onDefault), processorName(!onDefault)
+	indexFlag, totalsFlag, earliestFlag := "0", "0", "1"
+	if p.indexLatestWins {
+		earliestFlag = "0"
+	}
+	if index && p.processorIndexes {
+		indexFlag = "1"
+		if p.totals == nil {
+			totalsFlag = "1"
+		}
+	}
+
+	keys := []string{
+		k,
+		p.getPaymentsIndexKey(),
+		p.getPaymentsVersionKey(),
+		p.getProcessorIndexKey(processor),
+		p.getProcessorTotalsKey(),
+		p.getProcessorIndexKey(other),
+	}
+	changed, err := savePaymentScript.Run(ctx, p.cache, keys,
+		record,
+		at,
+		processor,
+		strconv.FormatFloat(amount, 'f', -1, 64),
+		indexFlag,
+		totalsFlag,
+		earliestFlag,
+		other,
+	).Int64Slice()
+	if err != nil {
+		return fmt.Errorf("error on saving processed payments: %w", err)
+	}
+	if index {
+		p.countProcessorPayment(onDefault, amount, changed[0] == 1, changed[1] == 1)
+	}
+	return nil
+}
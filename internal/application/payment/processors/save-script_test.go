@@ -0,0 +1,62 @@
+package payment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestSavePaymentScriptIsAtomic(t *testing.T) {
+	record := testRecord(1)
+
+	tests := []struct {
+		name string
+		// occupy makes one of the writes fail
+		occupy func(p *PaymentProcessor, mr *miniredis.Miniredis)
+	}{
+		{
+			name: "record key of another type",
+			occupy: func(p *PaymentProcessor, mr *miniredis.Miniredis) {
+				mr.HSet(p.getPaymentKey(record.CorrelationId), "field", "value")
+			},
+		},
+		{
+			name: "index key of another type",
+			occupy: func(p *PaymentProcessor, mr *miniredis.Miniredis) {
+				mr.Set(p.getPaymentsIndexKey(), "value")
+			},
+		},
+		{
+			name: "processor totals key of another type",
+			occupy: func(p *PaymentProcessor, mr *miniredis.Miniredis) {
+				mr.Set(p.getProcessorTotalsKey(), "value")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, mr := newTestProcessor(t)
+			p.EnableProcessorIndexes()
+			tt.occupy(p, mr)
+
+			if err := p.store.Save(context.Background(), testEpoch, record); err == nil {
+				t.Fatal("Save succeeded, want an error")
+			}
+
+			k := p.getPaymentKey(record.CorrelationId)
+			if _, err := mr.ZScore(p.getPaymentsIndexKey(), k); err == nil {
+				t.Error("payment was indexed without its record")
+			}
+			if mr.Type(k) == "string" {
+				t.Error("record was stored without its index entry")
+			}
+			if _, err := mr.ZScore(p.getProcessorIndexKey(processorName(record.OnDefault)), k); err == nil {
+				t.Error("payment was indexed under its processor")
+			}
+			if mr.Exists(p.getPaymentsVersionKey()) {
+				t.Error("payments version was bumped")
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// PaymentStore persists processed payments and walks them back by timestamp
+// (unix millis) for the summary.
+type PaymentStore interface {
+	Save(ctx context.Context, at int64, record models.PaymentRecord) error
+	// Range calls fn with every payment stored in the window and the
+	// timestamp it is stored at, which is what the window is matched on.
+	Range(ctx context.Context, from, to int64, fn func(at int64, record models.PaymentRecord)) error
+	// Version changes whenever a payment is saved, even saved again with
+	// the same timestamp, or removed.
+	Version(ctx context.Context) (int64, error)
+	Close() error
+}
+
+const rangeChunkSize = 1000
+
+// a failed page read is retried this many times, waiting rangeRetryBackoff,
+// doubled each time, so a Redis blip doesn't fail the whole summary
+const (
+	rangeRetries      = 2
+	rangeRetryBackoff = 20 * time.Millisecond
+)
+
+// retryRead runs read until it succeeds, giving up after rangeRetries retries
+// or once ctx is done.
+func retryRead(ctx context.Context, read func() error) error {
+	backoff := rangeRetryBackoff
+	for try := 0; ; try++ {
+		err := read()
+		if err == nil || try >= rangeRetries {
+			return err
+		}
+		fmt.Printf("failed to read payments page, retrying in %s: %v\n", backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+type redisStore struct {
+	p *PaymentProcessor
+}
+
+func (s *redisStore) Save(ctx context.Context, at int64, record models.PaymentRecord) error {
+	p := s.p
+	j, err := p.codec.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error on marshalling processed payment: %w", err)
+	}
+
+	k := p.getPaymentKey(record.CorrelationId)
+	if p.coalescer != nil {
+		return p.coalescer.write(ctx, paymentWrite{
+			key:       k,
+			record:    j,
+			at:        at,
+			onDefault: record.OnDefault,
+			amount:    record.Amount,
+			dryRun:    record.DryRun,
+		})
+	}
+
+	if p.saveMode != SaveModeLua {
+		return p.runSavePaymentPipeline(ctx, k, j, at, record.OnDefault, record.Amount, !record.DryRun)
+	}
+	return p.runSavePaymentScript(ctx, k, j, at, record.OnDefault, record.Amount, !record.DryRun)
+}
+
+// Range reads the window a page of rangeChunkSize keys at a time, so memory
+// stays bounded however wide it is. Pages resume from the last score seen,
+// skipping the keys already read at that score, so payments saved meanwhile
+// at earlier scores don't shift the pages.
+func (s *redisStore) Range(ctx context.Context, from, to int64, fn func(at int64, record models.PaymentRecord)) error {
+	p := s.p
+	minScore := fmt.Sprint(from)
+	var offset int64
+	for {
+		// the client may be gone already, no point in reading the rest
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var page []redis.Z
+		err := retryRead(ctx, func() (err error) {
+			page, err = p.cache.ZRangeByScoreWithScores(ctx, p.getPaymentsIndexKey(), &redis.ZRangeBy{
+				Min:    minScore,
+				Max:    fmt.Sprint(to),
+				Offset: offset,
+				Count:  rangeChunkSize,
+			}).Result()
+			return err
+		})
+		if err != nil {
+			fmt.Println(err)
+			return fmt.Errorf("failed to get payments to summarize")
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		keys := make([]string, len(page))
+		for i, z := range page {
+			keys[i] = z.Member.(string)
+		}
+		var results []any
+		err = retryRead(ctx, func() (err error) {
+			results, err = p.cache.MGet(ctx, keys...).Result()
+			return err
+		})
+		if err != nil {
+			fmt.Println(err)
+			return fmt.Errorf("failed to get payments")
+		}
+
+		for i, result := range results {
+			if result == nil {
+				continue
+			}
+			payment := models.PaymentRecord{}
+			err := p.codec.Unmarshal([]byte(result.(string)), &payment)
+			if err != nil {
+				continue
+			}
+			fn(int64(page[i].Score), payment)
+		}
+
+		if len(page) < rangeChunkSize {
+			break
+		}
+		lastScore := page[len(page)-1].Score
+		if fmt.Sprint(int64(lastScore)) != minScore {
+			minScore = fmt.Sprint(int64(lastScore))
+			offset = 0
+		}
+		for i := len(page) - 1; i >= 0 && page[i].Score == lastScore; i-- {
+			offset++
+		}
+	}
+
+	return nil
+}
+
+func (s *redisStore) Version(ctx context.Context) (int64, error) {
+	p := s.p
+	version, err := p.cache.Get(ctx, p.getPaymentsVersionKey()).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("error on getting payments version: %w", err)
+	}
+	return version, nil
+}
+
+func (s *redisStore) Close() error {
+	if s.p.coalescer != nil {
+		s.p.coalescer.close()
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/money"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisStoreRangePagesMatchSingleShot(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	ctx := context.Background()
+
+	// seven payments per milli, so page boundaries fall inside a score
+	const payments = 2*rangeChunkSize + 500
+	for i := range payments {
+		if err := p.store.Save(ctx, testEpoch+int64(i/7), testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to int64
+	}{
+		{name: "everything", from: testEpoch, to: testEpoch + payments},
+		{name: "one page", from: testEpoch + 100, to: testEpoch + 200},
+		// 1057 payments, one more page than a chunk
+		{name: "across pages", from: testEpoch + 140, to: testEpoch + 290},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := singleShotSummary(t, p, tt.from, tt.to)
+
+			seen := map[string]int{}
+			err := p.store.Range(ctx, tt.from, tt.to, func(_ int64, record models.PaymentRecord) {
+				seen[record.CorrelationId]++
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, n := range seen {
+				if n != 1 {
+					t.Errorf("payment %s read %d times", id, n)
+				}
+			}
+
+			// latency skips the processor totals and goes through Range
+			got, err := p.summaryPayments(ctx, tt.from, tt.to, SummaryOptions{Latency: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Default.TotalRequests != want.Default.TotalRequests ||
+				got.Default.TotalAmount != want.Default.TotalAmount ||
+				got.Fallback.TotalRequests != want.Fallback.TotalRequests ||
+				got.Fallback.TotalAmount != want.Fallback.TotalAmount {
+				t.Errorf("paged summary %+v %+v, single shot %+v %+v",
+					got.Default, got.Fallback, want.Default, want.Fallback)
+			}
+		})
+	}
+}
+
+func TestRedisStoreRangeRetriesFailedReads(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		fails   int64
+		wantErr bool
+	}{
+		{name: "page read recovers", command: "mget", fails: 1},
+		{name: "index read recovers", command: "zrangebyscore", fails: 1},
+		{name: "page read recovers on the last retry", command: "mget", fails: rangeRetries},
+		{name: "page read retries exhausted", command: "mget", fails: rangeRetries + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestProcessor(t)
+			saveTestRecords(t, p, 10)
+			failNext(p, tt.command, tt.fails)
+
+			read := 0
+			err := p.store.Range(context.Background(), testEpoch, testEpoch+10, func(_ int64, _ models.PaymentRecord) {
+				read++
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Range succeeded with every read failing")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Range error = %v, want it to retry", err)
+			}
+			if read != 10 {
+				t.Errorf("read %d payments, want 10", read)
+			}
+		})
+	}
+}
+
+func TestRedisStoreRangeStopsOnCancel(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	saveTestRecords(t, p, 2*rangeChunkSize+1)
+
+	t.Run("range", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the client hangs up while the first page is being summed
+		read := 0
+		err := p.store.Range(ctx, testEpoch, testEpoch+3*rangeChunkSize, func(_ int64, _ models.PaymentRecord) {
+			read++
+			cancel()
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Range error = %v, want context.Canceled", err)
+		}
+		if read != rangeChunkSize {
+			t.Errorf("read %d payments, want only the first page of %d", read, rangeChunkSize)
+		}
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// latency skips the processor totals and goes through Range
+		_, err := p.SummaryPayments(ctx, testEpoch, testEpoch+3*rangeChunkSize, SummaryOptions{Latency: true})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("SummaryPayments error = %v, want context.Canceled", err)
+		}
+	})
+}
+
+// singleShotSummary totals the window's payments read in a single
+// ZRANGEBYSCORE and MGET.
+func singleShotSummary(tb testing.TB, p *PaymentProcessor, from, to int64) models.PaymentsSummaryResponse {
+	tb.Helper()
+	ctx := context.Background()
+	keys, err := p.cache.ZRangeByScore(ctx, p.getPaymentsIndexKey(), &redis.ZRangeBy{
+		Min: fmt.Sprint(from),
+		Max: fmt.Sprint(to),
+	}).Result()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	results, err := p.cache.MGet(ctx, keys...).Result()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	res := models.PaymentsSummaryResponse{}
+	var defaultAmount, fallbackAmount money.Sum
+	for _, result := range results {
+		record := models.PaymentRecord{}
+		if err := p.codec.Unmarshal([]byte(result.(string)), &record); err != nil {
+			tb.Fatal(err)
+		}
+		if record.OnDefault {
+			res.Default.TotalRequests++
+			defaultAmount.Add(record.Amount)
+		} else {
+			res.Fallback.TotalRequests++
+			fallbackAmount.Add(record.Amount)
+		}
+	}
+	res.Default.TotalAmount = defaultAmount.Round(p.precision)
+	res.Fallback.TotalAmount = fallbackAmount.Round(p.precision)
+	return res
+}
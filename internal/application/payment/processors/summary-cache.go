@@ -0,0 +1,140 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// summaryCacheKey is a window rounded down to the cache granularity.
+type summaryCacheKey struct {
+	from int64
+	to   int64
+	opts SummaryOptions
+}
+
+type summaryCacheEntry struct {
+	// from and to are the exact window the summary was computed for
+	from      int64
+	to        int64
+	version   int64
+	expiresAt time.Time
+	summary   models.PaymentsSummaryResponse
+}
+
+// summaryCache keeps computed summaries per from/to window, rounded so
+// repeated queries a few millis apart share an entry. Entries are tagged with
+// the payments version they were computed at, so a save from any instance
+// bumps the version and makes them stale.
+type summaryCache struct {
+	ttl time.Duration
+	// granularity is in millis, 1 keeps windows exact
+	granularity int64
+	entries     map[summaryCacheKey]summaryCacheEntry
+	mu          sync.Mutex
+}
+
+func newSummaryCache(ttl, granularity time.Duration) *summaryCache {
+	return &summaryCache{
+		ttl:         ttl,
+		granularity: max(granularity.Milliseconds(), 1),
+		entries:     make(map[summaryCacheKey]summaryCacheEntry),
+	}
+}
+
+func (c *summaryCache) key(from, to int64, opts SummaryOptions) summaryCacheKey {
+	return summaryCacheKey{
+		from: from - mod(from, c.granularity),
+		to:   to - mod(to, c.granularity),
+		opts: opts,
+	}
+}
+
+// mod is a % b for a positive b, never negative so times before the epoch
+// round down too.
+func mod(a, b int64) int64 {
+	return (a%b + b) % b
+}
+
+// get returns the entry cached for the rounded window, which may have been
+// computed for a slightly different exact one.
+func (c *summaryCache) get(from, to int64, opts SummaryOptions, version int64) (summaryCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := c.key(from, to, opts)
+	entry, ok := c.entries[k]
+	if !ok {
+		return entry, false
+	}
+	if entry.version != version || time.Now().After(entry.expiresAt) {
+		delete(c.entries, k)
+		return entry, false
+	}
+	return entry, true
+}
+
+func (c *summaryCache) set(from, to int64, opts SummaryOptions, version int64, summary *models.PaymentsSummaryResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.key(from, to, opts)] = summaryCacheEntry{
+		from:      from,
+		to:        to,
+		version:   version,
+		expiresAt: time.Now().Add(c.ttl),
+		summary:   *summary,
+	}
+}
+
+// invalidate drops every window containing the payment timestamp, and
+// anything already expired while we are at it.
+func (c *summaryCache) invalidate(at int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if (at >= entry.from && at <= entry.to) || now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// samePayments reports whether the windows [fromA, toA] and [fromB, toB] hold
+// the same payments, which is when no payment is stored where they differ.
+// Only the Redis store can tell cheaply, any other answers false.
+func (p *PaymentProcessor) samePayments(ctx context.Context, fromA, toA, fromB, toB int64) bool {
+	if _, onRedis := p.store.(*redisStore); !onRedis {
+		return false
+	}
+
+	pipe := p.cache.Pipeline()
+	var counts []*redis.IntCmd
+	if fromA != fromB {
+		counts = append(counts, pipe.ZCount(ctx, p.getPaymentsIndexKey(),
+			fmt.Sprint(min(fromA, fromB)), fmt.Sprintf("(%d", max(fromA, fromB))))
+	}
+	if toA != toB {
+		counts = append(counts, pipe.ZCount(ctx, p.getPaymentsIndexKey(),
+			fmt.Sprintf("(%d", min(toA, toB)), fmt.Sprint(max(toA, toB))))
+	}
+	if len(counts) == 0 {
+		return true
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Println("failed to compare summary windows:", err)
+		return false
+	}
+
+	for _, count := range counts {
+		if count.Val() != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+)
+
+// SummaryETag builds a weak ETag for a summary window out of the payments
+// version, which moves on every save, resaves under the other processor too.
+func (p *PaymentProcessor) SummaryETag(ctx context.Context, from, to int64, opts SummaryOptions) (string, error) {
+	version, err := p.store.Version(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error on getting summary etag: %w", err)
+	}
+
+	return fmt.Sprintf(`W/"%d-%d-%d-%+v"`, version, from, to, opts), nil
+}
@@ -0,0 +1,55 @@
+package payment
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSummaryETagChangesOnResave(t *testing.T) {
+	tests := []struct {
+		name      string
+		fileStore bool
+	}{
+		{name: "redis"},
+		{name: "file", fileStore: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestProcessor(t)
+			if tt.fileStore {
+				if err := p.UseFileStore(filepath.Join(t.TempDir(), "payments.ndjson")); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(p.Close)
+			}
+			ctx := context.Background()
+			etag := func() string {
+				t.Helper()
+				tag, err := p.SummaryETag(ctx, testEpoch, testEpoch+10, SummaryOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tag
+			}
+
+			record := testRecord(1)
+			if err := p.store.Save(ctx, testEpoch+1, record); err != nil {
+				t.Fatal(err)
+			}
+			before := etag()
+			if again := etag(); again != before {
+				t.Fatalf("etag moved from %s to %s with no save", before, again)
+			}
+
+			// same payment, same timestamp, now on the other processor
+			record.OnDefault = !record.OnDefault
+			if err := p.store.Save(ctx, testEpoch+1, record); err != nil {
+				t.Fatal(err)
+			}
+			if after := etag(); after == before {
+				t.Errorf("etag %s unchanged after resaving the payment on the other processor", after)
+			}
+		})
+	}
+}
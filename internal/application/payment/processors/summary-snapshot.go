@@ -0,0 +1,149 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+)
+
+const (
+	snapshotDefault = iota
+	snapshotFallback
+	snapshotDryRun
+)
+
+type snapshotTotals [3]struct {
+	count  int
+	amount float64
+}
+
+// summarySnapshot holds every stored payment sorted by the timestamp it is
+// stored at, with running totals, so any from/to window is answered with two
+// binary searches and matches what a summary over the store would count. It
+// is only valid while the store version hasn't moved.
+type summarySnapshot struct {
+	takenAt   time.Time
+	version   int64
+	at        []int64
+	prefix    []snapshotTotals
+	precision int
+}
+
+type snapshotEntry struct {
+	at     int64
+	kind   int
+	amount float64
+}
+
+// TakeSummarySnapshot loads every stored payment into memory so later
+// summaries skip the store entirely, until the next payment is saved.
+func (p *PaymentProcessor) TakeSummarySnapshot(ctx context.Context) (time.Time, error) {
+	version, err := p.store.Version(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var entries []snapshotEntry
+	err = p.store.Range(ctx, math.MinInt64, math.MaxInt64, func(at int64, payment models.PaymentRecord) {
+		if payment.Version != models.PaymentRecordVersion {
+			return
+		}
+
+		kind := snapshotFallback
+		if payment.DryRun {
+			kind = snapshotDryRun
+		} else if payment.OnDefault {
+			kind = snapshotDefault
+		}
+		entries = append(entries, snapshotEntry{
+			at:     at,
+			kind:   kind,
+			amount: payment.Amount,
+		})
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error on taking summary snapshot: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].at < entries[j].at
+	})
+
+	snapshot := &summarySnapshot{
+		takenAt:   time.Now().UTC(),
+		version:   version,
+		at:        make([]int64, len(entries)),
+		prefix:    make([]snapshotTotals, len(entries)+1),
+		precision: p.precision,
+	}
+	for i, entry := range entries {
+		snapshot.at[i] = entry.at
+		snapshot.prefix[i+1] = snapshot.prefix[i]
+		snapshot.prefix[i+1][entry.kind].count++
+		snapshot.prefix[i+1][entry.kind].amount += entry.amount
+	}
+
+	p.snapshotMutex.Lock()
+	p.snapshot = snapshot
+	p.snapshotMutex.Unlock()
+	return snapshot.takenAt, nil
+}
+
+// SnapshotSummary answers the summary from the snapshot when there is one and
+// no payment was stored since. ok is false whenever the regular path is needed.
+func (p *PaymentProcessor) SnapshotSummary(ctx context.Context, from, to int64, opts SummaryOptions) (res *models.PaymentsSummaryResponse, takenAt time.Time, ok bool) {
+	if opts.Latency {
+		return nil, time.Time{}, false
+	}
+
+	p.snapshotMutex.RLock()
+	snapshot := p.snapshot
+	p.snapshotMutex.RUnlock()
+	if snapshot == nil {
+		return nil, time.Time{}, false
+	}
+
+	version, err := p.store.Version(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return nil, time.Time{}, false
+	}
+	if version != snapshot.version {
+		p.snapshotMutex.Lock()
+		if p.snapshot == snapshot {
+			p.snapshot = nil
+		}
+		p.snapshotMutex.Unlock()
+		return nil, time.Time{}, false
+	}
+
+	return snapshot.summary(from, to), snapshot.takenAt, true
+}
+
+func (s *summarySnapshot) summary(from, to int64) *models.PaymentsSummaryResponse {
+	lo := sort.Search(len(s.at), func(i int) bool { return s.at[i] >= from })
+	hi := sort.Search(len(s.at), func(i int) bool { return s.at[i] > to })
+	if hi < lo {
+		hi = lo
+	}
+
+	window := func(kind int) models.PaymentsSummary {
+		return models.PaymentsSummary{
+			TotalRequests: s.prefix[hi][kind].count - s.prefix[lo][kind].count,
+			TotalAmount:   roundAmount(s.prefix[hi][kind].amount-s.prefix[lo][kind].amount, s.precision),
+		}
+	}
+
+	res := &models.PaymentsSummaryResponse{
+		Default:  window(snapshotDefault),
+		Fallback: window(snapshotFallback),
+	}
+	if dryRun := window(snapshotDryRun); dryRun.TotalRequests > 0 {
+		res.DryRun = &dryRun
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSnapshotMatchesStoredTimestamps(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	saveTestRecords(t, p, 10)
+	ctx := context.Background()
+
+	// processed again later, the index keeps the earliest timestamp while the
+	// record now says otherwise
+	record := testRecord(0)
+	record.RequestedAt = time.UnixMilli(testEpoch + 500).UTC().Format(time.RFC3339Nano)
+	if err := p.store.Save(ctx, testEpoch+500, record); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.TakeSummarySnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, window := range [][2]int64{{testEpoch, testEpoch + 4}, {testEpoch + 5, testEpoch + 1000}} {
+		from, to := window[0], window[1]
+		want, err := p.summaryPayments(ctx, from, to, SummaryOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, ok := p.SnapshotSummary(ctx, from, to, SummaryOptions{})
+		if !ok {
+			t.Fatal("snapshot was not served")
+		}
+		if got.Default != want.Default || got.Fallback != want.Fallback {
+			t.Errorf("window %d-%d: snapshot %+v, summary %+v", from, to, *got, *want)
+		}
+	}
+}
+
+func TestSnapshotInvalidatedByResave(t *testing.T) {
+	p, _ := newTestProcessor(t)
+	saveTestRecords(t, p, 10)
+	ctx := context.Background()
+
+	if _, err := p.TakeSummarySnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// same payment count and latest timestamp, but a different processor
+	record := testRecord(9)
+	record.OnDefault = !record.OnDefault
+	if err := p.store.Save(ctx, testEpoch+9, record); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, ok := p.SnapshotSummary(ctx, testEpoch, testEpoch+9, SummaryOptions{}); ok {
+		t.Error("stale snapshot was served")
+	}
+}
@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	"github.com/payment-processor-rinha/internal/money"
+)
+
+type bucketSums struct {
+	bucket         models.SummaryBucket
+	defaultAmount  money.Sum
+	fallbackAmount money.Sum
+}
+
+// SummaryTimeSeries splits the summary between from and to into buckets of the
+// given size, aligned to the unix epoch. Buckets without payments are left out,
+// so the result grows with the payments and not with the range. Dry-run
+// records are not counted.
+func (p *PaymentProcessor) SummaryTimeSeries(ctx context.Context, from, to int64, bucket time.Duration) ([]models.SummaryBucket, error) {
+	release, err := p.acquireSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	size := bucket.Milliseconds()
+	sums := map[int64]*bucketSums{}
+	err = p.store.Range(ctx, from, to, func(_ int64, payment models.PaymentRecord) {
+		if payment.Version != models.PaymentRecordVersion || payment.DryRun {
+			return
+		}
+		requestedAt, err := time.Parse(time.RFC3339Nano, payment.RequestedAt)
+		if err != nil {
+			return
+		}
+
+		at := requestedAt.UnixMilli()
+		start := at - at%size
+		s, ok := sums[start]
+		if !ok {
+			s = &bucketSums{bucket: models.SummaryBucket{Start: start}}
+			sums[start] = s
+		}
+
+		if payment.OnDefault {
+			s.bucket.Default.TotalRequests++
+			s.defaultAmount.Add(payment.Amount)
+			return
+		}
+		s.bucket.Fallback.TotalRequests++
+		s.fallbackAmount.Add(payment.Amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]models.SummaryBucket, 0, len(sums))
+	for _, s := range sums {
+		s.bucket.Default.TotalAmount = s.defaultAmount.Round(p.precision)
+		s.bucket.Fallback.TotalAmount = s.fallbackAmount.Round(p.precision)
+		buckets = append(buckets, s.bucket)
+	}
+	slices.SortFunc(buckets, func(a, b models.SummaryBucket) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	return buckets, nil
+}
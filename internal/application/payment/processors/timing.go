@@ -0,0 +1,67 @@
+package payment
+
+import (
+	"context"
+	"time"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+)
+
+// EnableTiming stores when each payment was enqueued, picked up by a worker
+// and saved, for TimingBreakdown. It costs a few dozen bytes per record.
+func (p *PaymentProcessor) EnableTiming() {
+	p.timing = true
+}
+
+func (p *PaymentProcessor) paymentTiming(task *tasks.ProcessPaymentTask, completedAt time.Time) *models.PaymentTiming {
+	if !p.timing || task.StartedAt.IsZero() {
+		return nil
+	}
+
+	timing := &models.PaymentTiming{
+		StartedAt:   task.StartedAt.UTC().Format(time.RFC3339Nano),
+		CompletedAt: completedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if !task.EnqueuedAt.IsZero() {
+		timing.EnqueuedAt = task.EnqueuedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return timing
+}
+
+// TimingBreakdown summarizes the stored timings of the payments in the window.
+// Payments saved without timing are left out.
+func (p *PaymentProcessor) TimingBreakdown(ctx context.Context, from, to int64) (*models.TimingBreakdown, error) {
+	res := models.TimingBreakdown{}
+	var queueWaits, processing []float64
+	err := p.store.Range(ctx, from, to, func(_ int64, payment models.PaymentRecord) {
+		if payment.Timing == nil {
+			return
+		}
+		startedAt, err := time.Parse(time.RFC3339Nano, payment.Timing.StartedAt)
+		if err != nil {
+			return
+		}
+		completedAt, err := time.Parse(time.RFC3339Nano, payment.Timing.CompletedAt)
+		if err != nil {
+			return
+		}
+
+		res.Payments++
+		processing = append(processing, millisBetween(startedAt, completedAt))
+		if enqueuedAt, err := time.Parse(time.RFC3339Nano, payment.Timing.EnqueuedAt); err == nil {
+			queueWaits = append(queueWaits, millisBetween(enqueuedAt, startedAt))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res.QueueWait = *latencySummary(queueWaits)
+	res.Processing = *latencySummary(processing)
+	return &res, nil
+}
+
+func millisBetween(start, end time.Time) float64 {
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
@@ -0,0 +1,101 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// totalsBuffer accumulates the per-processor running totals in memory and
+// adds them to Redis every interval. Until a flush the totals lag behind the
+// indexes, so processorTotals sees the counts disagree and falls back to a
+// full scan rather than answering with stale numbers.
+type totalsBuffer struct {
+	p       *PaymentProcessor
+	counts  map[string]int64
+	amounts map[string]float64
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// SetTotalsFlushInterval batches the running totals updates, flushing them
+// every interval instead of with each payment. Zero keeps them synchronous.
+// It only matters with processor indexes enabled.
+func (p *PaymentProcessor) SetTotalsFlushInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	p.totals = newTotalsBuffer(p, interval)
+}
+
+func newTotalsBuffer(p *PaymentProcessor, interval time.Duration) *totalsBuffer {
+	b := &totalsBuffer{
+		p:       p,
+		counts:  make(map[string]int64),
+		amounts: make(map[string]float64),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// add buffers a change of a processor's totals, negative when payments
+// stopped counting for it.
+func (b *totalsBuffer) add(processor string, count int64, amount float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.counts[processor] += count
+	b.amounts[processor] += amount
+}
+
+func (b *totalsBuffer) run(interval time.Duration) {
+	defer close(b.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.stop:
+			b.flush()
+			return
+		}
+		b.flush()
+	}
+}
+
+func (b *totalsBuffer) flush() {
+	b.mu.Lock()
+	counts, amounts := b.counts, b.amounts
+	b.counts = make(map[string]int64)
+	b.amounts = make(map[string]float64)
+	b.mu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	pipe := b.p.cache.TxPipeline()
+	for processor, count := range counts {
+		b.p.queueTotalsChange(ctx, pipe, processor, count, amounts[processor])
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Println("failed to flush processor totals:", err)
+		// MULTI/EXEC applied none of it, keep it for the next flush
+		b.mu.Lock()
+		for processor, count := range counts {
+			b.counts[processor] += count
+			b.amounts[processor] += amounts[processor]
+		}
+		b.mu.Unlock()
+	}
+}
+
+// close flushes what is still pending and stops the flush loop.
+func (b *totalsBuffer) close() {
+	close(b.stop)
+	<-b.stopped
+}
@@ -0,0 +1,39 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTotalsBufferKeepsTotalsOnFailedFlush(t *testing.T) {
+	p, mr := newTestProcessor(t)
+	p.EnableProcessorIndexes()
+	p.SetTotalsFlushInterval(time.Hour)
+	t.Cleanup(p.Close)
+	saveTestRecords(t, p, 6)
+	ctx := context.Background()
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	p.totals.flush()
+	mr.SetError("")
+
+	// more payments counted while the failed flush was put back
+	for i := 6; i < 9; i++ {
+		if err := p.store.Save(ctx, testEpoch+int64(i), testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.totals.flush()
+
+	res, ok, err := p.processorTotals(ctx, testEpoch, testEpoch+9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("totals disagree with the indexes")
+	}
+	if res.Default.TotalRequests != 6 || res.Fallback.TotalRequests != 3 {
+		t.Errorf("totals = %d default, %d fallback, want 6 and 3", res.Default.TotalRequests, res.Fallback.TotalRequests)
+	}
+}
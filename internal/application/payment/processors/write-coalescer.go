@@ -0,0 +1,139 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type paymentWrite struct {
+	key       string
+	record    []byte
+	at        int64
+	onDefault bool
+	amount    float64
+	dryRun    bool
+	done      chan error
+}
+
+// writeCoalescer gathers payment saves from every worker and writes them in a
+// single transaction, with one ZADD for the whole batch, either every interval
+// or as soon as maxBatch writes are waiting.
+type writeCoalescer struct {
+	p        *PaymentProcessor
+	interval time.Duration
+	maxBatch int
+	pending  []paymentWrite
+	mu       sync.Mutex
+	full     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func newWriteCoalescer(p *PaymentProcessor, interval time.Duration, maxBatch int) *writeCoalescer {
+	c := &writeCoalescer{
+		p:        p,
+		interval: interval,
+		maxBatch: maxBatch,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// write queues a save and waits until its batch has been flushed.
+func (c *writeCoalescer) write(ctx context.Context, w paymentWrite) error {
+	w.done = make(chan error, 1)
+
+	c.mu.Lock()
+	c.pending = append(c.pending, w)
+	full := len(c.pending) >= c.maxBatch
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *writeCoalescer) run() {
+	defer close(c.stopped)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.full:
+		case <-c.stop:
+			c.flush()
+			return
+		}
+		c.flush()
+	}
+}
+
+func (c *writeCoalescer) flush() {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	values := make([]any, 0, len(batch)*2)
+	members := make([]redis.Z, 0, len(batch))
+	for _, w := range batch {
+		values = append(values, w.key, w.record)
+		members = append(members, redis.Z{
+			Score:  float64(w.at),
+			Member: w.key,
+		})
+	}
+
+	pipe := c.p.cache.TxPipeline()
+	pipe.MSet(ctx, values...)
+	c.p.zAddIndex(ctx, pipe, c.p.getPaymentsIndexKey(), members...)
+	var changes []processorIndexChange
+	for _, w := range batch {
+		if w.dryRun {
+			continue
+		}
+		if change, ok := c.p.indexProcessorPayment(ctx, pipe, w.key, w.at, w.onDefault, w.amount); ok {
+			changes = append(changes, change)
+		}
+	}
+	pipe.Incr(ctx, c.p.getPaymentsVersionKey())
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		err = fmt.Errorf("error on saving processed payments batch: %w", err)
+	} else if len(changes) > 0 {
+		c.p.settleProcessorTotals(ctx, changes)
+	}
+
+	for _, w := range batch {
+		w.done <- err
+	}
+}
+
+// close flushes whatever is still pending and stops the flush loop.
+func (c *writeCoalescer) close() {
+	close(c.stop)
+	<-c.stopped
+}
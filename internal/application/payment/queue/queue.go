@@ -0,0 +1,243 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Item is a raw payment task along with when it was accepted.
+type Item struct {
+	Data       []byte
+	EnqueuedAt time.Time
+}
+
+// Queue is a bounded deque of raw payment tasks. It drains FIFO, unless a LIFO
+// threshold is set and the depth goes over it, in which case the freshest
+// tasks are handed out first.
+type Queue struct {
+	items         []Item
+	head          int
+	size          int
+	lifoThreshold int
+	inFlight      int
+	closed        bool
+	maxBytes      int64
+	// bytes is the size of the queued payloads. Only written under mu, but
+	// atomic so Bytes doesn't contend with the workers.
+	bytes atomic.Int64
+
+	lastPushAt time.Time
+	drainedAt  time.Time
+	onDrained  func(DrainStats)
+	mu         sync.Mutex
+	notEmpty   *sync.Cond
+}
+
+func New(maxSize int) *Queue {
+	q := &Queue{
+		items: make([]Item, maxSize),
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	return q
+}
+
+// EnableLIFOAbove switches draining to LIFO while more than threshold tasks are queued.
+func (q *Queue) EnableLIFOAbove(threshold int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lifoThreshold = threshold
+}
+
+// SetMaxBytes also rejects new tasks once the queued payloads add up to more
+// than maxBytes, on top of the item count. Zero disables it.
+func (q *Queue) SetMaxBytes(maxBytes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxBytes = maxBytes
+}
+
+// Bytes returns the size of the queued payloads.
+func (q *Queue) Bytes() int64 {
+	return q.bytes.Load()
+}
+
+// DrainStats describes how the queue recovered after the last burst.
+type DrainStats struct {
+	Depth          int
+	Bytes          int64
+	InFlight       int
+	LastAcceptedAt time.Time
+	// DrainedAt is zero until the queue empties after the last accepted task.
+	DrainedAt   time.Time
+	TimeToDrain time.Duration
+}
+
+// OnDrained registers fn to run, in its own goroutine, every time the queue
+// goes fully idle after accepting tasks.
+func (q *Queue) OnDrained(fn func(DrainStats)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onDrained = fn
+}
+
+func (q *Queue) DrainStats() DrainStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.drainStatsLocked()
+}
+
+func (q *Queue) drainStatsLocked() DrainStats {
+	stats := DrainStats{
+		Depth:          q.size,
+		Bytes:          q.bytes.Load(),
+		InFlight:       q.inFlight,
+		LastAcceptedAt: q.lastPushAt,
+	}
+	if !q.drainedAt.IsZero() && q.drainedAt.After(q.lastPushAt) {
+		stats.DrainedAt = q.drainedAt
+		stats.TimeToDrain = q.drainedAt.Sub(q.lastPushAt)
+	}
+	return stats
+}
+
+// TryPush enqueues without blocking, returning false when the queue is full,
+// over its byte limit or closed.
+func (q *Queue) TryPush(item []byte) bool {
+	return q.TryPushItem(Item{
+		Data:       item,
+		EnqueuedAt: time.Now(),
+	})
+}
+
+// TryPushItem is TryPush for a task accepted earlier, like one coming back
+// from Redis, keeping its EnqueuedAt so its age counts the time it was away.
+func (q *Queue) TryPushItem(item Item) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed || q.size == len(q.items) {
+		return false
+	}
+	if q.maxBytes > 0 && q.bytes.Load()+int64(len(item.Data)) > q.maxBytes {
+		return false
+	}
+	q.bytes.Add(int64(len(item.Data)))
+	q.items[(q.head+q.size)%len(q.items)] = item
+	q.lastPushAt = time.Now()
+	q.size++
+	q.notEmpty.Signal()
+	return true
+}
+
+// Pop blocks until a task is available. It returns false once the queue is
+// closed and drained. Every popped task must be acknowledged with Done.
+func (q *Queue) Pop() (Item, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.size == 0 {
+		if q.closed {
+			return Item{}, false
+		}
+		q.notEmpty.Wait()
+	}
+
+	if q.lifoThreshold > 0 && q.size > q.lifoThreshold {
+		tail := (q.head + q.size - 1) % len(q.items)
+		item := q.items[tail]
+		q.items[tail] = Item{}
+		q.bytes.Add(-int64(len(item.Data)))
+		q.size--
+		q.inFlight++
+		return item, true
+	}
+
+	item := q.items[q.head]
+	q.items[q.head] = Item{}
+	q.bytes.Add(-int64(len(item.Data)))
+	q.head = (q.head + 1) % len(q.items)
+	q.size--
+	q.inFlight++
+	return item, true
+}
+
+// Done marks a popped task as finished.
+func (q *Queue) Done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+
+	if q.size == 0 && q.inFlight == 0 && q.drainedAt.Before(q.lastPushAt) {
+		q.drainedAt = time.Now()
+		if q.onDrained != nil {
+			go q.onDrained(q.drainStatsLocked())
+		}
+	}
+}
+
+func (q *Queue) drained() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size == 0 && q.inFlight == 0
+}
+
+// WaitDrained waits until nothing is queued or being processed, up to timeout.
+// It reports whether the queue was fully drained.
+func (q *Queue) WaitDrained(ctx context.Context, timeout time.Duration) bool {
+	if q.drained() {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Millisecond * 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return q.drained()
+		case <-ticker.C:
+			if q.drained() {
+				return true
+			}
+		}
+	}
+}
+
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+func (q *Queue) Cap() int {
+	return len(q.items)
+}
+
+// TakeRemaining empties the queue, returning the tasks still waiting in the
+// order they would have been popped FIFO. Meant for after Close, once the
+// workers are gone, to save what they didn't get to.
+func (q *Queue) TakeRemaining() []Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	remaining := make([]Item, 0, q.size)
+	for ; q.size > 0; q.size-- {
+		remaining = append(remaining, q.items[q.head])
+		q.items[q.head] = Item{}
+		q.head = (q.head + 1) % len(q.items)
+	}
+	q.bytes.Store(0)
+	return remaining
+}
+
+// Close stops accepting tasks and wakes every blocked Pop.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func task(i int) []byte {
+	return []byte(fmt.Sprintf("task-%d", i))
+}
+
+// popN pops n tasks, acknowledging each, and returns their payloads.
+func popN(tb testing.TB, q *Queue, n int) []string {
+	tb.Helper()
+	popped := make([]string, 0, n)
+	for range n {
+		item, ok := q.Pop()
+		if !ok {
+			tb.Fatalf("queue closed after %d of %d pops", len(popped), n)
+		}
+		q.Done()
+		popped = append(popped, string(item.Data))
+	}
+	return popped
+}
+
+// names are the payloads of task(from) through task(to).
+func names(from, to int) []string {
+	var n []string
+	for i := from; i <= to; i++ {
+		n = append(n, string(task(i)))
+	}
+	return n
+}
+
+func TestQueueFIFO(t *testing.T) {
+	q := New(5)
+	for i := range 5 {
+		if !q.TryPush(task(i)) {
+			t.Fatalf("push %d rejected", i)
+		}
+	}
+	if got, want := popN(t, q, 5), names(0, 4); !slices.Equal(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+}
+
+func TestQueueLIFOAboveThreshold(t *testing.T) {
+	q := New(10)
+	q.EnableLIFOAbove(2)
+	for i := 1; i <= 5; i++ {
+		q.TryPush(task(i))
+	}
+
+	// the freshest go first while over the threshold, then back to FIFO
+	want := []string{"task-5", "task-4", "task-3", "task-1", "task-2"}
+	if got := popN(t, q, 5); !slices.Equal(got, want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+}
+
+func TestQueueWrapsAround(t *testing.T) {
+	q := New(4)
+	next, popped := 0, 0
+	for round := range 10 {
+		for q.Len() < q.Cap() {
+			q.TryPush(task(next))
+			next++
+		}
+		if q.TryPush(task(next)) {
+			t.Fatalf("round %d: push accepted into a full queue", round)
+		}
+
+		// pop fewer than pushed so head keeps moving around the ring
+		got := popN(t, q, 3)
+		if want := names(popped, popped+2); !slices.Equal(got, want) {
+			t.Fatalf("round %d: popped %v, want %v", round, got, want)
+		}
+		popped += 3
+	}
+
+	q.Close()
+	var remaining []string
+	for _, item := range q.TakeRemaining() {
+		remaining = append(remaining, string(item.Data))
+	}
+	if want := names(popped, next-1); !slices.Equal(remaining, want) {
+		t.Errorf("remaining %v, want %v", remaining, want)
+	}
+	if q.Len() != 0 || q.Bytes() != 0 {
+		t.Errorf("len %d and %d bytes after TakeRemaining, want empty", q.Len(), q.Bytes())
+	}
+}
+
+func TestQueueMaxBytes(t *testing.T) {
+	q := New(10)
+	q.SetMaxBytes(10)
+
+	if !q.TryPush([]byte("12345")) || !q.TryPush([]byte("67890")) {
+		t.Fatal("pushes within the byte cap rejected")
+	}
+	if q.TryPush([]byte("x")) {
+		t.Error("push over the byte cap accepted")
+	}
+	if q.Bytes() != 10 {
+		t.Errorf("bytes = %d, want 10", q.Bytes())
+	}
+
+	popN(t, q, 1)
+	if q.Bytes() != 5 {
+		t.Errorf("bytes = %d after a pop, want 5", q.Bytes())
+	}
+	if !q.TryPush([]byte("abcde")) {
+		t.Error("push rejected once a pop made room")
+	}
+}
+
+func TestQueuePopAfterClose(t *testing.T) {
+	q := New(10)
+	q.TryPush(task(1))
+	q.Close()
+
+	if q.TryPush(task(2)) {
+		t.Error("push accepted after Close")
+	}
+	// what was queued before closing still drains
+	if got := popN(t, q, 1); !slices.Equal(got, names(1, 1)) {
+		t.Errorf("popped %v, want %v", got, names(1, 1))
+	}
+	if _, ok := q.Pop(); ok {
+		t.Error("Pop succeeded on a closed and drained queue")
+	}
+}
+
+func TestQueueCloseWakesPop(t *testing.T) {
+	q := New(10)
+	done := make(chan bool)
+	go func() {
+		_, ok := q.Pop()
+		done <- ok
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Pop returned a task from an empty queue")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop still blocked after Close")
+	}
+}
+
+func TestQueueDrainAccounting(t *testing.T) {
+	q := New(10)
+	drained := make(chan DrainStats, 1)
+	q.OnDrained(func(stats DrainStats) { drained <- stats })
+
+	q.TryPush(task(1))
+	q.TryPush(task(2))
+	q.Pop()
+	q.Pop()
+	if stats := q.DrainStats(); stats.Depth != 0 || stats.InFlight != 2 || !stats.DrainedAt.IsZero() {
+		t.Errorf("stats with two in flight = %+v, want depth 0, 2 in flight, not drained", stats)
+	}
+	if q.WaitDrained(context.Background(), 20*time.Millisecond) {
+		t.Error("drained with tasks still in flight")
+	}
+
+	q.Done()
+	select {
+	case <-drained:
+		t.Fatal("drained with a task still in flight")
+	default:
+	}
+	q.Done()
+
+	select {
+	case stats := <-drained:
+		if stats.InFlight != 0 || stats.DrainedAt.IsZero() || stats.TimeToDrain < 0 {
+			t.Errorf("drain stats = %+v, want drained with nothing in flight", stats)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDrained not called once the last task was done")
+	}
+	if !q.WaitDrained(context.Background(), time.Second) {
+		t.Error("WaitDrained false on an idle queue")
+	}
+}
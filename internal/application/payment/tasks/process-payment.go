@@ -1,5 +1,7 @@
 package payment
 
+import "time"
+
 type ProcessPaymentPayload struct {
 	CorrelationId string  `json:"correlationId"`
 	RequestedAt   string  `json:"requestedAt"`
@@ -8,10 +10,23 @@ type ProcessPaymentPayload struct {
 
 type ProcessPaymentTask struct {
 	ProcessPaymentPayload
-	OnDefault bool `json:"onDefault"`
-	Tries     int  `json:"tries"`
+	OnDefault       bool   `json:"onDefault"`
+	Tries           int    `json:"tries"`
+	ForcedProcessor string `json:"forcedProcessor,omitempty"`
+
+	// EnqueuedAt and StartedAt follow the task through this instance only,
+	// they are never sent to the processor.
+	EnqueuedAt time.Time `json:"-"`
+	StartedAt  time.Time `json:"-"`
+	// Tier is the position in the processor chain the payment was sent to.
+	Tier int `json:"-"`
 }
 
 const (
 	ProcessPayment = "payment:process"
 )
+
+const (
+	ProcessorDefault  = "default"
+	ProcessorFallback = "fallback"
+)
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+const maxAttemptTimeout = 30 * time.Second
+
+// attemptTimeout gives early tries of a task a short deadline, so they fail
+// fast on a hung processor, and later ones more room in case it is just slow.
+type attemptTimeout struct {
+	base   time.Duration
+	growth float64
+}
+
+// forAttempt returns the deadline for the given try, counting from 1:
+// base * growth^(tries-1), capped at maxAttemptTimeout.
+func (t attemptTimeout) forAttempt(tries int) time.Duration {
+	timeout := float64(t.base) * math.Pow(t.growth, float64(max(tries, 1)-1))
+	if timeout > float64(maxAttemptTimeout) {
+		return maxAttemptTimeout
+	}
+	return time.Duration(timeout)
+}
+
+// context bounds ctx by the try's deadline. A zero base leaves it unbounded.
+func (t attemptTimeout) context(ctx context.Context, tries int) (context.Context, context.CancelFunc) {
+	if t.base <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, t.forAttempt(tries))
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAttemptTimeoutForAttempt(t *testing.T) {
+	timeout := attemptTimeout{base: 500 * time.Millisecond, growth: 2}
+	tests := []struct {
+		tries int
+		want  time.Duration
+	}{
+		{tries: 0, want: 500 * time.Millisecond},
+		{tries: 1, want: 500 * time.Millisecond},
+		{tries: 2, want: time.Second},
+		{tries: 3, want: 2 * time.Second},
+		{tries: 6, want: 16 * time.Second},
+		{tries: 7, want: maxAttemptTimeout},
+		{tries: 5000, want: maxAttemptTimeout},
+	}
+	for _, tt := range tests {
+		if got := timeout.forAttempt(tt.tries); got != tt.want {
+			t.Errorf("forAttempt(%d) = %s, want %s", tt.tries, got, tt.want)
+		}
+	}
+}
+
+func TestAttemptTimeoutContext(t *testing.T) {
+	ctx, cancel := attemptTimeout{}.context(context.Background(), 3)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("zero base set a deadline")
+	}
+
+	start := time.Now()
+	ctx, cancel = attemptTimeout{base: time.Second, growth: 1.5}.context(context.Background(), 3)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("no deadline set")
+	}
+	if got, want := deadline.Sub(start), 2250*time.Millisecond; got < want || got > want+100*time.Millisecond {
+		t.Errorf("third try deadline in %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+)
+
+type ClockSyncPool struct {
+	pp *paymentProcessor.PaymentProcessor
+	wg sync.WaitGroup
+}
+
+func NewClockSyncPool(pp *paymentProcessor.PaymentProcessor) *ClockSyncPool {
+	return &ClockSyncPool{
+		pp: pp,
+	}
+}
+
+// StartClockSyncWorker refreshes the offset to Redis' clock right away and then
+// every interval.
+func (wp *ClockSyncPool) StartClockSyncWorker(ctx context.Context, interval time.Duration) {
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := wp.pp.SyncClock(ctx); err != nil {
+				fmt.Println(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the clock sync goroutine has returned.
+func (wp *ClockSyncPool) Wait() {
+	wp.wg.Wait()
+}
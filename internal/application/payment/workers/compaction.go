@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+)
+
+type CompactionPool struct {
+	pp        *paymentProcessor.PaymentProcessor
+	startedAt time.Time
+	wg        sync.WaitGroup
+}
+
+func NewCompactionPool(pp *paymentProcessor.PaymentProcessor) *CompactionPool {
+	return &CompactionPool{
+		pp:        pp,
+		startedAt: time.Now(),
+	}
+}
+
+// StartCompactionWorker drops payments older than retention every interval.
+// Payments saved since this instance started are always kept, so the current
+// run's window is never trimmed.
+func (wp *CompactionPool) StartCompactionWorker(ctx context.Context, interval, retention time.Duration) {
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			cutoff := time.Now().Add(-retention)
+			if cutoff.After(wp.startedAt) {
+				cutoff = wp.startedAt
+			}
+
+			removed, err := wp.pp.CompactPayments(ctx, cutoff.UTC().UnixMilli())
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if removed > 0 {
+				fmt.Printf("compacted %d payments\n", removed)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the compaction goroutine has returned.
+func (wp *CompactionPool) Wait() {
+	wp.wg.Wait()
+}
@@ -2,27 +2,96 @@ package worker
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
 )
 
+const healthCheckInterval = time.Second * 5
+
 type HealthCheckPool struct {
-	pp *paymentProcessor.PaymentProcessor
+	pp          *paymentProcessor.PaymentProcessor
+	interval    time.Duration
+	probeOffset time.Duration
+	started     atomic.Bool
+	wg          sync.WaitGroup
 }
 
 func NewHealthCheckPool(pp *paymentProcessor.PaymentProcessor) *HealthCheckPool {
 	return &HealthCheckPool{
-		pp: pp,
+		pp:       pp,
+		interval: healthCheckInterval,
 	}
 }
 
-func (wp *HealthCheckPool) StartHealthCheckWorker(masterInst bool) {
-	ctx := context.Background()
+// SetProbeOffset makes the master probe default and fallback on their own
+// tickers, the fallback one starting offset after the default one, instead
+// of only probing the processor in use. Zero keeps the single probe.
+func (wp *HealthCheckPool) SetProbeOffset(offset time.Duration) {
+	wp.probeOffset = offset
+}
+
+// StartHealthCheckWorker starts probing. Only the first call does anything,
+// more loops would just multiply the probes and fight over the up flag.
+func (wp *HealthCheckPool) StartHealthCheckWorker(ctx context.Context, masterInst bool) {
+	if !wp.started.CompareAndSwap(false, true) {
+		fmt.Println("health check worker already started, ignoring")
+		return
+	}
+
+	if masterInst && wp.probeOffset > 0 {
+		wp.startProcessorProbe(ctx, true, 0)
+		wp.startProcessorProbe(ctx, false, wp.probeOffset)
+		return
+	}
+
+	wp.wg.Add(1)
 	go func() {
+		defer wp.wg.Done()
+		ticker := time.NewTicker(wp.interval)
+		defer ticker.Stop()
 		for {
-			time.Sleep(time.Second * 5)
-			wp.pp.HealthCheck(ctx, masterInst)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				wp.pp.HealthCheck(ctx, masterInst)
+			}
 		}
 	}()
 }
+
+func (wp *HealthCheckPool) startProcessorProbe(ctx context.Context, onDefault bool, offset time.Duration) {
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		if offset > 0 {
+			timer := time.NewTimer(offset)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		ticker := time.NewTicker(wp.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				wp.pp.HealthCheckProcessor(ctx, onDefault)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the health-check goroutines have returned.
+func (wp *HealthCheckPool) Wait() {
+	wp.wg.Wait()
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartHealthCheckWorkerTwiceRunsOneLoop(t *testing.T) {
+	var probes, inFlight, maxInFlight atomic.Int64
+	pp, _ := newTestProcessor(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		// a loop waits for its probe, so a second one would show up meanwhile
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"failing":false,"minResponseTime":0}`))
+	})
+
+	wp := NewHealthCheckPool(pp)
+	wp.interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	wp.StartHealthCheckWorker(ctx, true)
+	wp.StartHealthCheckWorker(ctx, true)
+
+	waitFor(t, time.Second, func() bool { return probes.Load() >= 4 })
+	cancel()
+	wp.Wait()
+
+	if maxInFlight.Load() != 1 {
+		t.Errorf("%d probes in flight at once, want a single loop probing", maxInFlight.Load())
+	}
+}
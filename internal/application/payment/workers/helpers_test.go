@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/redis/go-redis/v9"
+)
+
+// acceptPayments is a processor taking every payment.
+func acceptPayments(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// newTestProcessor returns a processor, up, whose default and fallback are
+// both served by handler, saving to an in-memory Redis.
+func newTestProcessor(tb testing.TB, handler http.HandlerFunc) (*paymentProcessor.PaymentProcessor, *miniredis.Miniredis) {
+	tb.Helper()
+	server := httptest.NewServer(handler)
+	tb.Cleanup(server.Close)
+	return newTestProcessorAt(tb, server.URL)
+}
+
+// newTestProcessorAt returns a processor, up, whose default and fallback are
+// both at url.
+func newTestProcessorAt(tb testing.TB, url string) (*paymentProcessor.PaymentProcessor, *miniredis.Miniredis) {
+	tb.Helper()
+	tb.Setenv("PROCESSOR_DEFAULT_URL", url)
+	tb.Setenv("PROCESSOR_FALLBACK_URL", url)
+
+	mr := miniredis.RunT(tb)
+	registerBLMPop(tb, mr)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tb.Cleanup(func() { client.Close() })
+
+	pp := paymentProcessor.NewPaymentProcessor(context.Background(), client)
+	pp.SetUp(true)
+	return pp, mr
+}
+
+// waitFor polls cond until it holds, failing the test after timeout.
+func waitFor(tb testing.TB, timeout time.Duration, cond func() bool) {
+	tb.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			tb.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// registerBLMPop teaches mr the BLMPOP the Redis-backed queue is consumed
+// with, which miniredis lacks. It only pops from the left and waits about
+// 10ms for a task instead of the whole timeout.
+func registerBLMPop(tb testing.TB, mr *miniredis.Miniredis) {
+	tb.Helper()
+	err := mr.Server().Register("BLMPOP", func(c *server.Peer, cmd string, args []string) {
+		// BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+		numKeys, _ := strconv.Atoi(args[1])
+		keys := args[2 : 2+numKeys]
+		count := 1
+		if opts := args[3+numKeys:]; len(opts) == 2 {
+			count, _ = strconv.Atoi(opts[1])
+		}
+
+		for range 10 {
+			for _, key := range keys {
+				var popped []string
+				for len(popped) < count {
+					value, err := mr.Lpop(key)
+					if err != nil {
+						break
+					}
+					popped = append(popped, value)
+				}
+				if len(popped) > 0 {
+					c.WriteLen(2)
+					c.WriteBulk(key)
+					c.WriteStrings(popped)
+					return
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+		c.WriteNull()
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+)
+
+type OverflowPool struct {
+	pp    *paymentProcessor.PaymentProcessor
+	queue *queue.Queue
+	wg    sync.WaitGroup
+}
+
+func NewOverflowPool(pp *paymentProcessor.PaymentProcessor, queue *queue.Queue) *OverflowPool {
+	return &OverflowPool{
+		pp:    pp,
+		queue: queue,
+	}
+}
+
+// StartOverflowWorker moves tasks parked in Redis back into the in-memory
+// queue whenever it has room for them.
+func (wp *OverflowPool) StartOverflowWorker(ctx context.Context) {
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		for {
+			if !wp.drain(ctx) {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Millisecond * 100):
+				}
+			}
+		}
+	}()
+}
+
+// drain reports whether it moved anything, so the caller knows when to idle.
+func (wp *OverflowPool) drain(ctx context.Context) bool {
+	room := wp.queue.Cap() - wp.queue.Len()
+	if room <= 0 {
+		return false
+	}
+
+	overflowed, err := wp.pp.PopOverflow(ctx, room)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	if len(overflowed) == 0 {
+		return false
+	}
+
+	for i, task := range overflowed {
+		if !wp.queue.TryPushItem(task) {
+			if err := wp.pp.RequeueOverflow(context.WithoutCancel(ctx), overflowed[i:]); err != nil {
+				fmt.Println(err)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Wait blocks until the overflow goroutine has returned.
+func (wp *OverflowPool) Wait() {
+	wp.wg.Wait()
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+)
+
+// ParkQueue moves whatever the closed queue still holds to Redis. It must run
+// once the payment workers are done. It returns how many tasks were parked.
+func ParkQueue(ctx context.Context, pp *paymentProcessor.PaymentProcessor, q *queue.Queue) (int, error) {
+	remaining := q.TakeRemaining()
+	if err := pp.ParkQueued(ctx, remaining); err != nil {
+		return 0, err
+	}
+	return len(remaining), nil
+}
+
+// RestoreParkedQueue fills the queue with tasks parked by a previous instance,
+// as many as fit, before any new payment is accepted. The rest stay parked.
+// It returns how many tasks were restored.
+func RestoreParkedQueue(ctx context.Context, pp *paymentProcessor.PaymentProcessor, q *queue.Queue) (int, error) {
+	room := q.Cap() - q.Len()
+	if room <= 0 {
+		return 0, nil
+	}
+
+	parked, err := pp.PopParked(ctx, room)
+	if err != nil {
+		return 0, err
+	}
+	for i, task := range parked {
+		if !q.TryPushItem(task) {
+			return i, pp.UnpopParked(ctx, parked[i:])
+		}
+	}
+	return len(parked), nil
+}
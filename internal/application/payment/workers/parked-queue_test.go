@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+)
+
+func testPaymentN(i int) []byte {
+	return []byte(fmt.Sprintf(`{"correlationId":"00000000-0000-0000-0000-%012d","amount":19.9}`, i))
+}
+
+func TestParkAndRestoreQueue(t *testing.T) {
+	pp, _ := newTestProcessor(t, acceptPayments)
+	ctx := context.Background()
+
+	enqueuedAt := time.UnixMilli(time.Now().Add(-time.Minute).UnixMilli())
+	q := queue.New(10)
+	for i := range 3 {
+		q.TryPushItem(queue.Item{Data: testPaymentN(i), EnqueuedAt: enqueuedAt})
+	}
+	q.Close()
+
+	parked, err := ParkQueue(ctx, pp, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parked != 3 {
+		t.Fatalf("%d tasks parked, want 3", parked)
+	}
+
+	// a smaller queue takes what fits, the rest waits for the next restore
+	var restored []queue.Item
+	for _, size := range []int{2, 10} {
+		next := queue.New(size)
+		n, err := RestoreParkedQueue(ctx, pp, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := min(size, 3-len(restored)); n != want {
+			t.Fatalf("%d tasks restored into a queue of %d, want %d", n, size, want)
+		}
+		next.Close()
+		restored = append(restored, next.TakeRemaining()...)
+	}
+
+	for i, item := range restored {
+		if string(item.Data) != string(testPaymentN(i)) {
+			t.Errorf("restored task %d = %s, want %s", i, item.Data, testPaymentN(i))
+		}
+		if !item.EnqueuedAt.Equal(enqueuedAt) {
+			t.Errorf("restored task %d enqueued at %s, want %s", i, item.EnqueuedAt, enqueuedAt)
+		}
+	}
+	if left, _ := pp.PopParked(ctx, 10); len(left) != 0 {
+		t.Errorf("%d tasks still parked after restoring them all", len(left))
+	}
+}
+
+func TestPaymentWorkerParksHeldTaskAtShutdown(t *testing.T) {
+	tests := []struct {
+		name           string
+		park           bool
+		wantParked     int
+		wantDeadLetter int64
+	}{
+		{name: "parked", park: true, wantParked: 1},
+		{name: "dead-lettered", park: false, wantDeadLetter: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp, mr := newTestProcessor(t, acceptPayments)
+			pp.SetUp(false)
+
+			q := queue.New(10)
+			q.TryPush([]byte(testPayment))
+
+			// the worker holds the task, waiting for the processor to come up
+			ctx, cancel := context.WithCancel(context.Background())
+			wp := NewPaymentWorker(pp, q, 1)
+			wp.SetParkOnShutdown(tt.park)
+			wp.StartPaymentWorker(ctx, 10)
+			waitFor(t, time.Second, func() bool { return wp.States().WaitingForUp == 1 })
+
+			cancel()
+			q.Close()
+			wp.Wait()
+			if _, err := ParkQueue(context.Background(), pp, q); err != nil {
+				t.Fatal(err)
+			}
+
+			_, deadLetters, err := pp.DeadLetters(context.Background(), 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deadLetters != tt.wantDeadLetter {
+				t.Errorf("%d dead letters, want %d", deadLetters, tt.wantDeadLetter)
+			}
+
+			// the next instance picks the parked task up and processes it
+			pp.SetUp(true)
+			next := queue.New(10)
+			restored, err := RestoreParkedQueue(context.Background(), pp, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored != tt.wantParked {
+				t.Fatalf("%d tasks restored, want %d", restored, tt.wantParked)
+			}
+			next.Close()
+			nextWp := NewPaymentWorker(pp, next, 1)
+			nextWp.StartPaymentWorker(context.Background(), 10)
+			nextWp.Wait()
+
+			saved := mr.Exists("payments:4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3")
+			if saved != tt.park {
+				t.Errorf("payment saved after restart = %t, want %t", saved, tt.park)
+			}
+		})
+	}
+}
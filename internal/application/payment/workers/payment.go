@@ -2,88 +2,332 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	json "github.com/json-iterator/go"
 	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
 	paymentTask "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
 )
 
 type PaymentWorkerPool struct {
-	pp          *paymentProcessor.PaymentProcessor
-	concurrency int
-	queue       chan []byte
-	maxRetries  int
+	pp             *paymentProcessor.PaymentProcessor
+	concurrency    int
+	queue          *queue.Queue
+	maxRetries     int
+	maxConnRetries int
+	retryBudget    *retryBudget
+	maxQueueAge    time.Duration
+	attemptTimeout attemptTimeout
+	jitter         string
+	parkOnShutdown bool
+	results        *resultLogger
+	states         workerStateCounters
+	// lastQueueAnalysis is when a worker last warned about a full queue, in unix nanos
+	lastQueueAnalysis atomic.Int64
+	wg                sync.WaitGroup
 }
 
-func NewPaymentWorker(pp *paymentProcessor.PaymentProcessor, queue chan []byte, concurrency int) *PaymentWorkerPool {
-	return &PaymentWorkerPool{
+func NewPaymentWorker(pp *paymentProcessor.PaymentProcessor, queue *queue.Queue, concurrency int) *PaymentWorkerPool {
+	wp := &PaymentWorkerPool{
 		pp:          pp,
 		concurrency: concurrency,
 		queue:       queue,
 		maxRetries:  5,
+		jitter:      JitterAdditive,
+		states: workerStateCounters{
+			processed: make([]atomic.Int64, concurrency),
+		},
 	}
+	wp.lastQueueAnalysis.Store(time.Now().UnixNano())
+	return wp
 }
 
-var lastQueueAnalysis = time.Now()
+// EnableRetryBudget caps the retries per second across all workers. Tasks that
+// cannot get a retry token in time are sent to the dead-letter queue.
+func (wp *PaymentWorkerPool) EnableRetryBudget(perSecond int) {
+	wp.retryBudget = newRetryBudget(perSecond)
+}
+
+// SetMaxRetries changes how many times a task is tried before being
+// dead-lettered. Zero or less retries forever.
+func (wp *PaymentWorkerPool) SetMaxRetries(maxRetries int) {
+	wp.maxRetries = maxRetries
+}
+
+// SetMaxConnRetries dead-letters a task once the processor could not be
+// reached that many times, independently of SetMaxRetries. Zero disables it.
+func (wp *PaymentWorkerPool) SetMaxConnRetries(maxConnRetries int) {
+	wp.maxConnRetries = maxConnRetries
+}
+
+// SetMaxQueueAge dead-letters tasks that waited in the queue longer than
+// maxAge instead of processing them late. Zero disables it.
+func (wp *PaymentWorkerPool) SetMaxQueueAge(maxAge time.Duration) {
+	wp.maxQueueAge = maxAge
+}
 
-func (wp *PaymentWorkerPool) StartPaymentWorker(queueMaxSize int) {
+// SetResultLogSampling logs the outcome of one in every tries of a payment,
+// with its processor and try count. Zero disables it.
+func (wp *PaymentWorkerPool) SetResultLogSampling(every int) {
+	wp.results = newResultLogger(every)
+}
+
+// SetAttemptTimeout bounds the first try of a task to base and multiplies the
+// bound by growth on each retry, up to 30s. Zero base leaves tries unbounded.
+func (wp *PaymentWorkerPool) SetAttemptTimeout(base time.Duration, growth float64) {
+	wp.attemptTimeout = attemptTimeout{base: base, growth: growth}
+}
+
+// SetParkOnShutdown parks the tasks workers are holding when shut down in
+// Redis, next to the parked queue, instead of dead-lettering them.
+func (wp *PaymentWorkerPool) SetParkOnShutdown(park bool) {
+	wp.parkOnShutdown = park
+}
+
+// StartPaymentWorker runs the workers until the queue is closed and drained or
+// ctx is cancelled. Tasks already picked up are finished even after ctx is
+// cancelled, except while waiting on the processor or Redis or backing off,
+// in which case they are dead-lettered, or parked with SetParkOnShutdown.
+func (wp *PaymentWorkerPool) StartPaymentWorker(ctx context.Context, queueMaxSize int) {
 	for i := range wp.concurrency {
-		ctx := context.Background()
-		ctx.Value(i)
+		ctx := withWorkerID(ctx, i)
+		wp.wg.Add(1)
 		go func() {
-			for buff := range wp.queue {
-				ql := len(wp.queue)
-				if time.Since(lastQueueAnalysis) > time.Second*3 && float64(ql) >= float64(queueMaxSize)*0.9 {
-					fmt.Printf("queue is almost full %d\n", ql)
-					lastQueueAnalysis = time.Now()
+			defer wp.wg.Done()
+			for {
+				if ctx.Err() != nil {
+					return
 				}
 
-				for !wp.pp.IsUp() {
-					time.Sleep(time.Millisecond * 100)
+				item, ok := wp.queue.Pop()
+				if !ok {
+					return
 				}
 
-				task := paymentTask.ProcessPaymentTask{}
-				err := json.Unmarshal(buff, &task)
-				if err != nil {
-					fmt.Printf("error when unmarshal task %s\n", err.Error())
-					panic(err)
+				ql := wp.queue.Len()
+				if float64(ql) >= float64(queueMaxSize)*0.9 && wp.claimQueueAnalysis() {
+					fmt.Printf("worker %d: queue is almost full %d\n", i, ql)
 				}
 
-				tries := 0
-				for {
-					tries++
-					if tries > wp.maxRetries {
-						fmt.Printf("max retries reached for task %s\n", task.CorrelationId)
-						break
-					}
+				wp.process(ctx, item)
+				wp.queue.Done()
+				wp.states.processed[i].Add(1)
+			}
+		}()
+	}
+}
 
-					if err := wp.pp.ProcessTask(ctx, task); err == nil {
-						break
-					}
+// claimQueueAnalysis reports whether the caller may warn about the queue,
+// letting a single worker through every 3 seconds.
+func (wp *PaymentWorkerPool) claimQueueAnalysis() bool {
+	last := wp.lastQueueAnalysis.Load()
+	now := time.Now().UnixNano()
+	if time.Duration(now-last) <= time.Second*3 {
+		return false
+	}
+	return wp.lastQueueAnalysis.CompareAndSwap(last, now)
+}
 
-					performBackoffWithJitter(tries)
-				}
+// States reports what the workers are busy with, to tell a slow processor
+// apart from workers stuck waiting or backing off.
+func (wp *PaymentWorkerPool) States() WorkerStates {
+	return wp.states.snapshot()
+}
+
+// Wait blocks until every worker goroutine has returned.
+func (wp *PaymentWorkerPool) Wait() {
+	wp.wg.Wait()
+}
+
+func (wp *PaymentWorkerPool) process(ctx context.Context, item queue.Item) {
+	id, _ := WorkerID(ctx)
+	task := paymentTask.ProcessPaymentTask{}
+	err := json.Unmarshal(item.Data, &task)
+	if err != nil {
+		fmt.Printf("worker %d: error when unmarshal task %s\n", id, err.Error())
+		panic(err)
+	}
+
+	task.EnqueuedAt = item.EnqueuedAt
+	task.StartedAt = time.Now()
+
+	// in-flight requests and saves must outlive a shutdown
+	processCtx := context.WithoutCancel(ctx)
+
+	if wp.maxQueueAge > 0 && time.Since(item.EnqueuedAt) > wp.maxQueueAge {
+		wp.deadLetter(processCtx, task, "expired in queue", 0)
+		return
+	}
+
+	if task.ForcedProcessor == "" {
+		leave := enter(&wp.states.waitingForUp)
+		err := wp.pp.WaitUp(ctx)
+		leave()
+		if err != nil {
+			wp.shutdown(processCtx, item, task, "shutdown while waiting for processor", 0)
+			return
+		}
+	}
+
+	tries, connFailures := 0, 0
+	for {
+		tries++
+		if wp.maxRetries > 0 && tries > wp.maxRetries {
+			fmt.Printf("worker %d: max retries reached for task %s\n", id, task.CorrelationId)
+			wp.deadLetter(processCtx, task, "max retries reached", tries-1)
+			return
+		}
+
+		if tries > 1 && wp.retryBudget != nil && !wp.retryBudget.take(retryBudgetMaxWait) {
+			fmt.Printf("worker %d: retry budget exhausted for task %s\n", id, task.CorrelationId)
+			wp.deadLetter(processCtx, task, "retry budget exhausted", tries-1)
+			return
+		}
+
+		// a processed payment we can't save is lost, so hold off while Redis is down
+		leave := enter(&wp.states.waitingForRedis)
+		err := wp.pp.WaitRedis(ctx)
+		leave()
+		if err != nil {
+			fmt.Printf("worker %d: shutdown while waiting for redis for task %s\n", id, task.CorrelationId)
+			wp.shutdown(processCtx, item, task, "shutdown while waiting for redis", tries-1)
+			return
+		}
+
+		leave = enter(&wp.states.processing)
+		attemptCtx, cancel := wp.attemptTimeout.context(processCtx, tries)
+		processed, err := wp.pp.ProcessTask(attemptCtx, task)
+		cancel()
+		leave()
+		wp.results.log(id, task, tries, processed, err)
+		if err == nil {
+			return
+		}
+		if reason := paymentProcessor.UnconfirmedReason(err); reason != "" {
+			// may have been charged, keep it around to reconcile instead of dropping it
+			wp.deadLetter(processCtx, task, reason, tries)
+			return
+		}
+		if errors.Is(err, paymentProcessor.ErrPermanent) {
+			fmt.Printf("worker %d: dropping task %s: %v\n", id, task.CorrelationId, err)
+			return
+		}
+		if errors.Is(err, paymentProcessor.ErrProcessorDown) {
+			connFailures++
+			if wp.maxConnRetries > 0 && connFailures >= wp.maxConnRetries {
+				fmt.Printf("worker %d: processor unreachable for task %s\n", id, task.CorrelationId)
+				wp.deadLetter(processCtx, task, "processor unreachable", tries)
+				return
 			}
-		}()
+		}
+
+		leave = enter(&wp.states.backingOff)
+		backedOff := performBackoffWithJitter(ctx, tries, wp.jitter)
+		leave()
+		if !backedOff {
+			wp.shutdown(processCtx, item, task, "shutdown while backing off", tries)
+			return
+		}
+	}
+}
+
+func (wp *PaymentWorkerPool) deadLetter(ctx context.Context, task paymentTask.ProcessPaymentTask, reason string, attempts int) {
+	task.Tries = attempts
+	if err := wp.pp.DeadLetter(ctx, task, reason, attempts); err != nil {
+		fmt.Println("failed to dead letter task:", err)
+	}
+}
+
+// shutdown gives up on a task held when the workers were stopped, parking it
+// as it was queued for the next instance if enabled.
+func (wp *PaymentWorkerPool) shutdown(ctx context.Context, item queue.Item, task paymentTask.ProcessPaymentTask, reason string, attempts int) {
+	if wp.parkOnShutdown {
+		err := wp.pp.ParkQueued(ctx, []queue.Item{item})
+		if err == nil {
+			return
+		}
+		fmt.Println("failed to park task:", err)
 	}
+	wp.deadLetter(ctx, task, reason, attempts)
 }
 
+const retryBudgetMaxWait = 1 * time.Second
+
 const baseDelay = 1 * time.Second
+const maxBackoff = 30 * time.Second
 const jitter = 250 * time.Millisecond
 
-func performBackoffWithJitter(tries int) {
+// Backoff jitter strategies, see
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+const (
+	// JitterNone waits exactly the exponential backoff.
+	JitterNone = "none"
+	// JitterAdditive adds up to 250ms on top of the backoff.
+	JitterAdditive = "additive"
+	// JitterFull waits anywhere between zero and the backoff.
+	JitterFull = "full"
+	// JitterEqual waits half the backoff plus up to the other half.
+	JitterEqual = "equal"
+)
+
+// SetBackoffJitter picks how retries of different tasks are spread out.
+// Defaults to JitterAdditive.
+func (wp *PaymentWorkerPool) SetBackoffJitter(strategy string) error {
+	if err := checkJitter(strategy); err != nil {
+		return err
+	}
+	wp.jitter = strategy
+	return nil
+}
+
+func checkJitter(strategy string) error {
+	switch strategy {
+	case JitterNone, JitterAdditive, JitterFull, JitterEqual:
+		return nil
+	}
+	return fmt.Errorf("unknown backoff jitter %q", strategy)
+}
+
+// backoffDelay is how long to wait before the next try.
+func backoffDelay(tries int, strategy string) time.Duration {
 	if tries < 1 {
 		tries = 1
 	}
 
-	// baseDelay * 2^(n-1)
-	backoff := baseDelay * time.Duration(1<<(tries-1))
+	// baseDelay * 2^(n-1), capped so unlimited retries don't overflow
+	backoff := maxBackoff
+	if tries <= 6 {
+		backoff = min(baseDelay*time.Duration(1<<(tries-1)), maxBackoff)
+	}
 
 	// evict "thundering herd"
-	randomJitter := time.Duration(rand.Intn(int(jitter)))
-	totalWait := backoff + randomJitter
-	time.Sleep(totalWait)
+	switch strategy {
+	case JitterNone:
+		return backoff
+	case JitterFull:
+		return time.Duration(rand.Int63n(int64(backoff) + 1))
+	case JitterEqual:
+		return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	default:
+		return backoff + time.Duration(rand.Intn(int(jitter)))
+	}
+}
+
+// performBackoffWithJitter sleeps before the next try, returning false if ctx
+// is cancelled first.
+func performBackoffWithJitter(ctx context.Context, tries int, strategy string) bool {
+	timer := time.NewTimer(backoffDelay(tries, strategy))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
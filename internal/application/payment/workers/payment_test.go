@@ -0,0 +1,206 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/payment-processor-rinha/internal/application/payment/queue"
+)
+
+const testPayment = `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9}`
+
+func TestPaymentWorkerDeadLettersUnreachableProcessor(t *testing.T) {
+	// nothing listens on a closed listener's address anymore
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp, _ := newTestProcessorAt(t, "http://"+l.Addr().String())
+	l.Close()
+
+	q := queue.New(10)
+	q.TryPush([]byte(testPayment))
+	q.Close()
+
+	// connection failures give up on their own count, long before maxRetries
+	wp := NewPaymentWorker(pp, q, 1)
+	wp.SetMaxRetries(5)
+	wp.SetMaxConnRetries(1)
+	wp.StartPaymentWorker(context.Background(), 10)
+	wp.Wait()
+
+	entries, total, err := pp.DeadLetters(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("%d dead letters, want 1", total)
+	}
+	if entries[0].Reason != "processor unreachable" || entries[0].Attempts != 1 {
+		t.Errorf("dead letter reason %q after %d attempts, want processor unreachable after 1",
+			entries[0].Reason, entries[0].Attempts)
+	}
+}
+
+func TestPaymentWorkerDeadLettersUnexpectedSuccess(t *testing.T) {
+	pp, mr := newTestProcessor(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	pp.SetSuccessStatuses([]int{http.StatusOK}, []int{http.StatusOK})
+
+	q := queue.New(10)
+	q.TryPush([]byte(testPayment))
+	q.Close()
+
+	wp := NewPaymentWorker(pp, q, 1)
+	wp.StartPaymentWorker(context.Background(), 10)
+	wp.Wait()
+
+	entries, total, err := pp.DeadLetters(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("%d dead letters, want the 201 parked instead of dropped", total)
+	}
+	if entries[0].Reason != "unexpected success status" || entries[0].Attempts != 1 {
+		t.Errorf("dead letter reason %q after %d attempts, want unexpected success status after 1",
+			entries[0].Reason, entries[0].Attempts)
+	}
+	if keys := mr.Keys(); slices.ContainsFunc(keys, func(k string) bool { return strings.Contains(k, "4a7901b8") }) {
+		t.Errorf("payment saved under %v, want it only dead-lettered", keys)
+	}
+}
+
+func TestPaymentWorkersRunConcurrently(t *testing.T) {
+	pp, mr := newTestProcessor(t, acceptPayments)
+
+	const payments = 200
+	q := queue.New(payments)
+	for i := range payments {
+		q.TryPush([]byte(fmt.Sprintf(`{"correlationId":"00000000-0000-0000-0000-%012d","amount":19.9}`, i)))
+	}
+	q.Close()
+
+	wp := NewPaymentWorker(pp, q, 8)
+	// every worker finds the queue almost full and races for the warning
+	wp.lastQueueAnalysis.Store(0)
+	wp.StartPaymentWorker(context.Background(), 1)
+	wp.Wait()
+
+	saved, err := mr.ZMembers("payments:by-date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != payments {
+		t.Errorf("%d payments saved, want %d", len(saved), payments)
+	}
+	var processed int64
+	for _, n := range wp.States().ProcessedByWorker {
+		processed += n
+	}
+	if processed != payments {
+		t.Errorf("workers processed %d payments, want %d", processed, payments)
+	}
+}
+
+func TestClaimQueueAnalysisLetsOneWorkerThrough(t *testing.T) {
+	wp := NewPaymentWorker(nil, queue.New(1), 1)
+	wp.lastQueueAnalysis.Store(0)
+
+	var claimed atomic.Int64
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if wp.claimQueueAnalysis() {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed.Load() != 1 {
+		t.Errorf("%d workers claimed the warning, want 1", claimed.Load())
+	}
+}
+
+func TestBackoffDelayBounds(t *testing.T) {
+	backoffs := []struct {
+		tries int
+		want  time.Duration
+	}{
+		{tries: 0, want: time.Second},
+		{tries: 1, want: time.Second},
+		{tries: 3, want: 4 * time.Second},
+		{tries: 5, want: 16 * time.Second},
+		{tries: 6, want: maxBackoff},
+		{tries: 1000, want: maxBackoff},
+	}
+	strategies := []struct {
+		strategy string
+		// lo and hi bound the delay for a backoff, both included
+		lo, hi func(backoff time.Duration) time.Duration
+	}{
+		{
+			strategy: JitterNone,
+			lo:       func(b time.Duration) time.Duration { return b },
+			hi:       func(b time.Duration) time.Duration { return b },
+		},
+		{
+			strategy: JitterAdditive,
+			lo:       func(b time.Duration) time.Duration { return b },
+			hi:       func(b time.Duration) time.Duration { return b + jitter - 1 },
+		},
+		{
+			strategy: JitterFull,
+			lo:       func(b time.Duration) time.Duration { return 0 },
+			hi:       func(b time.Duration) time.Duration { return b },
+		},
+		{
+			strategy: JitterEqual,
+			lo:       func(b time.Duration) time.Duration { return b / 2 },
+			hi:       func(b time.Duration) time.Duration { return b },
+		},
+	}
+	for _, s := range strategies {
+		t.Run(s.strategy, func(t *testing.T) {
+			for _, b := range backoffs {
+				lo, hi := s.lo(b.want), s.hi(b.want)
+				minSeen, maxSeen := time.Duration(math.MaxInt64), time.Duration(0)
+				for range 2000 {
+					d := backoffDelay(b.tries, s.strategy)
+					if d < lo || d > hi {
+						t.Fatalf("try %d: delay %s outside [%s, %s]", b.tries, d, lo, hi)
+					}
+					minSeen, maxSeen = min(minSeen, d), max(maxSeen, d)
+				}
+
+				// the delays should spread over most of the range, not stick to one end
+				if spread := hi - lo; spread > 0 && maxSeen-minSeen < spread*3/4 {
+					t.Errorf("try %d: delays only spread over [%s, %s] of [%s, %s]", b.tries, minSeen, maxSeen, lo, hi)
+				}
+			}
+		})
+	}
+}
+
+func TestSetBackoffJitterRejectsUnknownStrategy(t *testing.T) {
+	wp := NewPaymentWorker(nil, queue.New(1), 1)
+	if err := wp.SetBackoffJitter("decorrelated"); err == nil {
+		t.Error("unknown jitter strategy accepted")
+	}
+	if wp.jitter != JitterAdditive {
+		t.Errorf("jitter = %q after a rejected strategy, want the default %q", wp.jitter, JitterAdditive)
+	}
+}
@@ -0,0 +1,248 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+	paymentTask "github.com/payment-processor-rinha/internal/application/payment/tasks"
+	"github.com/payment-processor-rinha/internal/json"
+)
+
+const redisBatchPopTimeout = 1 * time.Second
+
+// due retries are moved back to the queue this often, up to
+// redisRetryPromoteBatch per round trip
+const (
+	redisRetryPromoteInterval = 100 * time.Millisecond
+	redisRetryPromoteBatch    = 1000
+)
+
+// RedisBatchWorkerPool consumes the Redis-backed queue, popping up to
+// batchSize tasks per round trip. Each task gets one attempt per pop; the
+// ones that fail with a retryable error are held back for the same backoff
+// as PaymentWorkerPool's retries before going back to the tail.
+type RedisBatchWorkerPool struct {
+	pp          *paymentProcessor.PaymentProcessor
+	concurrency int
+	batchSize   int
+	maxRetries  int
+	timeout     attemptTimeout
+	jitter      string
+	results     *resultLogger
+	requeued    atomic.Int64
+	wg          sync.WaitGroup
+}
+
+func NewRedisBatchWorker(pp *paymentProcessor.PaymentProcessor, concurrency, batchSize int) *RedisBatchWorkerPool {
+	return &RedisBatchWorkerPool{
+		pp:          pp,
+		concurrency: concurrency,
+		batchSize:   batchSize,
+		maxRetries:  5,
+		jitter:      JitterAdditive,
+	}
+}
+
+// SetBackoffJitter works like PaymentWorkerPool.SetBackoffJitter.
+func (wp *RedisBatchWorkerPool) SetBackoffJitter(strategy string) error {
+	if err := checkJitter(strategy); err != nil {
+		return err
+	}
+	wp.jitter = strategy
+	return nil
+}
+
+// SetMaxRetries changes how many pops a task gets before being dead-lettered.
+// Zero or less retries forever.
+func (wp *RedisBatchWorkerPool) SetMaxRetries(maxRetries int) {
+	wp.maxRetries = maxRetries
+}
+
+// SetResultLogSampling works like PaymentWorkerPool.SetResultLogSampling.
+func (wp *RedisBatchWorkerPool) SetResultLogSampling(every int) {
+	wp.results = newResultLogger(every)
+}
+
+// SetAttemptTimeout bounds each try like PaymentWorkerPool.SetAttemptTimeout,
+// counting tries across requeues.
+func (wp *RedisBatchWorkerPool) SetAttemptTimeout(base time.Duration, growth float64) {
+	wp.timeout = attemptTimeout{base: base, growth: growth}
+}
+
+func (wp *RedisBatchWorkerPool) StartRedisBatchWorker(ctx context.Context) {
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		wp.promoteRetries(ctx)
+	}()
+
+	for i := range wp.concurrency {
+		ctx := withWorkerID(ctx, i)
+		wp.wg.Add(1)
+		go func() {
+			defer wp.wg.Done()
+			for ctx.Err() == nil {
+				if err := wp.pp.WaitRedis(ctx); err != nil {
+					return
+				}
+
+				batch, err := wp.pp.PopQueue(ctx, wp.batchSize, redisBatchPopTimeout)
+				if err != nil {
+					if ctx.Err() == nil {
+						fmt.Printf("redis batch worker %d: %v\n", i, err)
+						time.Sleep(time.Millisecond * 100)
+					}
+					continue
+				}
+				if len(batch) == 0 {
+					continue
+				}
+
+				wp.processBatch(ctx, batch)
+			}
+		}()
+	}
+}
+
+// promoteRetries moves retries whose backoff is over back to the queue until
+// ctx is cancelled.
+func (wp *RedisBatchWorkerPool) promoteRetries(ctx context.Context) {
+	ticker := time.NewTicker(redisRetryPromoteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			moved, err := wp.pp.PromoteQueuedRetries(ctx, redisRetryPromoteBatch)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Println(err)
+				}
+				break
+			}
+			if moved < redisRetryPromoteBatch {
+				break
+			}
+		}
+	}
+}
+
+func (wp *RedisBatchWorkerPool) processBatch(ctx context.Context, batch [][]byte) {
+	// a popped batch is no longer in Redis, so finish it even on shutdown
+	processCtx := context.WithoutCancel(ctx)
+	id, _ := WorkerID(ctx)
+
+	failed := make([][]byte, 0, len(batch))
+	failedTasks := make([]paymentTask.ProcessPaymentTask, 0, len(batch))
+	delays := make([]time.Duration, 0, len(batch))
+	for i, buff := range batch {
+		task := paymentTask.ProcessPaymentTask{}
+		if err := json.Unmarshal(buff, &task); err != nil {
+			fmt.Printf("redis batch worker %d: error when unmarshal task %s\n", id, err.Error())
+			continue
+		}
+
+		task.StartedAt = time.Now()
+
+		if task.ForcedProcessor == "" {
+			if err := wp.pp.WaitUp(ctx); err != nil {
+				// shutting down while the processor is down, hand what is left
+				// of the batch back untried for another instance to pick up
+				wp.handBack(processCtx, batch[i:])
+				break
+			}
+		}
+
+		attemptCtx, cancel := wp.timeout.context(processCtx, task.Tries+1)
+		processed, err := wp.pp.ProcessTask(attemptCtx, task)
+		cancel()
+		wp.results.log(id, task, task.Tries+1, processed, err)
+		if err == nil {
+			continue
+		}
+		if reason := paymentProcessor.UnconfirmedReason(err); reason != "" {
+			// may have been charged, keep it around to reconcile instead of dropping it
+			if err := wp.pp.DeadLetter(processCtx, task, reason, task.Tries+1); err != nil {
+				fmt.Println("failed to dead letter task:", err)
+			}
+			continue
+		}
+		if errors.Is(err, paymentProcessor.ErrPermanent) {
+			fmt.Printf("redis batch worker %d: dropping task %s: %v\n", id, task.CorrelationId, err)
+			continue
+		}
+
+		task.Tries++
+		if wp.maxRetries > 0 && task.Tries >= wp.maxRetries {
+			fmt.Printf("redis batch worker %d: max retries reached for task %s\n", id, task.CorrelationId)
+			if err := wp.pp.DeadLetter(processCtx, task, "max retries reached", task.Tries); err != nil {
+				fmt.Println("failed to dead letter task:", err)
+			}
+			continue
+		}
+
+		retry, err := json.Marshal(task)
+		if err != nil {
+			fmt.Println("failed to marshal task for retry:", err)
+			continue
+		}
+		failed = append(failed, retry)
+		failedTasks = append(failedTasks, task)
+		delays = append(delays, backoffDelay(task.Tries, wp.jitter))
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	if err := wp.pp.RetryQueuedLater(processCtx, failed, delays); err != nil {
+		// nothing else holds these tasks anymore, park them rather than lose them
+		fmt.Printf("redis batch worker %d: failed to requeue tasks: %v\n", id, err)
+		for _, task := range failedTasks {
+			if err := wp.pp.DeadLetter(processCtx, task, "requeue failed", task.Tries); err != nil {
+				fmt.Println("failed to dead letter task:", err)
+			}
+		}
+		return
+	}
+	wp.requeued.Add(int64(len(failed)))
+}
+
+// handBack pushes popped tasks back to the queue as they were, dead-lettering
+// them when that fails too.
+func (wp *RedisBatchWorkerPool) handBack(ctx context.Context, batch [][]byte) {
+	id, _ := WorkerID(ctx)
+	err := wp.pp.PushQueue(ctx, batch...)
+	if err == nil {
+		return
+	}
+
+	fmt.Printf("redis batch worker %d: failed to hand back tasks: %v\n", id, err)
+	for _, buff := range batch {
+		task := paymentTask.ProcessPaymentTask{}
+		if err := json.Unmarshal(buff, &task); err != nil {
+			continue
+		}
+		if err := wp.pp.DeadLetter(ctx, task, "shutdown while waiting for processor", task.Tries); err != nil {
+			fmt.Println("failed to dead letter task:", err)
+		}
+	}
+}
+
+// Requeued returns how many tasks were scheduled for another try so far.
+func (wp *RedisBatchWorkerPool) Requeued() int64 {
+	return wp.requeued.Load()
+}
+
+// Wait blocks until every worker goroutine has returned.
+func (wp *RedisBatchWorkerPool) Wait() {
+	wp.wg.Wait()
+}
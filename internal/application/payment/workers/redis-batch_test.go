@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRedisBatchWorkerBacksOffRetries(t *testing.T) {
+	pp, mr := newTestProcessor(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	if err := pp.PushQueue(context.Background(), []byte(`{"correlationId":"retried","amount":19.9}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wp := NewRedisBatchWorker(pp, 1, 10)
+	if err := wp.SetBackoffJitter(JitterNone); err != nil {
+		t.Fatal(err)
+	}
+	failedAt := time.Now()
+	wp.StartRedisBatchWorker(ctx)
+	waitFor(t, 5*time.Second, func() bool { return wp.Requeued() == 1 })
+	cancel()
+	wp.Wait()
+
+	if queued, _ := mr.List("payments:queue"); len(queued) != 0 {
+		t.Errorf("retry went straight back to the queue: %v", queued)
+	}
+	retries, err := mr.ZMembers("payments:queue:retry")
+	if err != nil || len(retries) != 1 {
+		t.Fatalf("retry set = %v (%v), want the failed task", retries, err)
+	}
+	retryAt, _ := mr.ZScore("payments:queue:retry", retries[0])
+	if wait := time.UnixMilli(int64(retryAt)).Sub(failedAt); wait < baseDelay-10*time.Millisecond {
+		t.Errorf("retry scheduled %s after the failure, want at least %s", wait, baseDelay)
+	}
+}
+
+// BenchmarkRedisBatchWorker drains the Redis-backed queue with growing batch
+// sizes. The redis-cmds/payment metric shows the pops amortized across each
+// batch, on top of the four commands of each save (EVALSHA and the script's
+// ZADD, SET and INCR).
+func BenchmarkRedisBatchWorker(b *testing.B) {
+	const payments = 500
+
+	for _, batchSize := range []int{1, 10, 50} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			pp, mr := newTestProcessor(b, acceptPayments)
+			var commands, runs int
+			for b.Loop() {
+				b.StopTimer()
+				tasks := make([][]byte, payments)
+				for i := range tasks {
+					tasks[i] = fmt.Appendf(nil, `{"correlationId":"%d-%d","amount":19.9}`, runs, i)
+				}
+				if err := pp.PushQueue(context.Background(), tasks...); err != nil {
+					b.Fatal(err)
+				}
+				startedAt := mr.CommandCount()
+				ctx, cancel := context.WithCancel(context.Background())
+				b.StartTimer()
+
+				wp := NewRedisBatchWorker(pp, 4, batchSize)
+				wp.StartRedisBatchWorker(ctx)
+				waitFor(b, 10*time.Second, func() bool {
+					saved, _ := mr.ZMembers("payments:by-date")
+					return len(saved) == (runs+1)*payments
+				})
+
+				b.StopTimer()
+				commands += mr.CommandCount() - startedAt
+				runs++
+				cancel()
+				wp.Wait()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(commands)/float64(runs*payments), "redis-cmds/payment")
+		})
+	}
+}
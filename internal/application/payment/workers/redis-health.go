@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	paymentProcessor "github.com/payment-processor-rinha/internal/application/payment/processors"
+)
+
+type RedisHealthPool struct {
+	pp *paymentProcessor.PaymentProcessor
+	wg sync.WaitGroup
+}
+
+func NewRedisHealthPool(pp *paymentProcessor.PaymentProcessor) *RedisHealthPool {
+	return &RedisHealthPool{
+		pp: pp,
+	}
+}
+
+// StartRedisHealthWorker pings Redis every interval so workers pause while it
+// is unreachable instead of failing saves.
+func (wp *RedisHealthPool) StartRedisHealthWorker(ctx context.Context, interval time.Duration) {
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				wp.pp.CheckRedis(ctx)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the Redis health goroutine has returned.
+func (wp *RedisHealthPool) Wait() {
+	wp.wg.Wait()
+}
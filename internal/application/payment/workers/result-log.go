@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	paymentTask "github.com/payment-processor-rinha/internal/application/payment/tasks"
+)
+
+// resultLogger logs one in every tries of a payment, enough for a
+// representative trace without paying for a line per payment.
+type resultLogger struct {
+	every  int64
+	seen   atomic.Int64
+	logger *slog.Logger
+}
+
+func newResultLogger(every int) *resultLogger {
+	if every <= 0 {
+		return nil
+	}
+	return &resultLogger{
+		every:  int64(every),
+		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+	}
+}
+
+// log records the outcome of a try when it is sampled. processed is nil when
+// the try failed.
+func (l *resultLogger) log(workerID int, task paymentTask.ProcessPaymentTask, tries int, processed *paymentTask.ProcessPaymentTask, err error) {
+	if l == nil || l.seen.Add(1)%l.every != 0 {
+		return
+	}
+
+	attrs := []any{
+		slog.Int("worker", workerID),
+		slog.String("correlationId", task.CorrelationId),
+		slog.Int("try", tries),
+	}
+	if processed != nil {
+		processor := paymentTask.ProcessorFallback
+		if processed.OnDefault {
+			processor = paymentTask.ProcessorDefault
+		}
+		attrs = append(attrs, slog.String("processor", processor), slog.Int("tier", processed.Tier))
+	}
+	if err != nil {
+		l.logger.Warn("payment try failed", append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	l.logger.Info("payment processed", attrs...)
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// retryBudget is a token bucket shared by every worker, capping how many
+// retries per second the whole pool may send to the processors.
+type retryBudget struct {
+	capacity   float64
+	tokens     float64
+	lastRefill time.Time
+	mu         sync.Mutex
+}
+
+func newRetryBudget(perSecond int) *retryBudget {
+	return &retryBudget{
+		capacity:   float64(perSecond),
+		tokens:     float64(perSecond),
+		lastRefill: time.Now(),
+	}
+}
+
+func (b *retryBudget) tryTake() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.lastRefill).Seconds() * b.capacity
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// take waits up to maxWait for a token to become available.
+func (b *retryBudget) take(maxWait time.Duration) bool {
+	deadline := time.Now().Add(maxWait)
+	for {
+		if b.tryTake() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -0,0 +1,15 @@
+package worker
+
+import "context"
+
+type workerIDKey struct{}
+
+func withWorkerID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, workerIDKey{}, id)
+}
+
+// WorkerID returns the id of the worker goroutine ctx belongs to, if any.
+func WorkerID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(workerIDKey{}).(int)
+	return id, ok
+}
@@ -0,0 +1,42 @@
+package worker
+
+import "sync/atomic"
+
+// WorkerStates counts how many workers are in each state right now. Workers
+// idle on the queue or doing bookkeeping are in none of them.
+type WorkerStates struct {
+	Processing      int64 `json:"processing"`
+	WaitingForUp    int64 `json:"waitingForUp"`
+	WaitingForRedis int64 `json:"waitingForRedis"`
+	BackingOff      int64 `json:"backingOff"`
+	// ProcessedByWorker is how many tasks each worker finished, by worker id.
+	ProcessedByWorker []int64 `json:"processedByWorker"`
+}
+
+type workerStateCounters struct {
+	processing      atomic.Int64
+	waitingForUp    atomic.Int64
+	waitingForRedis atomic.Int64
+	backingOff      atomic.Int64
+	processed       []atomic.Int64
+}
+
+// enter counts a worker in state until the returned func is called.
+func enter(state *atomic.Int64) func() {
+	state.Add(1)
+	return func() { state.Add(-1) }
+}
+
+func (c *workerStateCounters) snapshot() WorkerStates {
+	processed := make([]int64, len(c.processed))
+	for i := range c.processed {
+		processed[i] = c.processed[i].Load()
+	}
+	return WorkerStates{
+		Processing:        c.processing.Load(),
+		WaitingForUp:      c.waitingForUp.Load(),
+		WaitingForRedis:   c.waitingForRedis.Load(),
+		BackingOff:        c.backingOff.Load(),
+		ProcessedByWorker: processed,
+	}
+}
@@ -0,0 +1,27 @@
+//go:build goccyjson && !stdjson
+
+package json
+
+import (
+	"io"
+
+	json "github.com/goccy/go-json"
+)
+
+const Library = "goccy/go-json"
+
+func Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
@@ -0,0 +1,14 @@
+// Package json is the single JSON entry point of the service. The backing
+// library is picked at build time: json-iterator (ConfigFastest, but with full
+// float precision) by default, encoding/json with -tags stdjson, or
+// goccy/go-json with -tags goccyjson.
+package json
+
+type Encoder interface {
+	Encode(v any) error
+}
+
+type Decoder interface {
+	Decode(v any) error
+	DisallowUnknownFields()
+}
@@ -0,0 +1,119 @@
+package json
+
+import (
+	"bytes"
+	"testing"
+
+	models "github.com/payment-processor-rinha/internal/application/payment/models"
+	tasks "github.com/payment-processor-rinha/internal/application/payment/tasks"
+)
+
+// TestMarshalSummaryBytes pins the summary as every backing library renders
+// it; run it with -tags stdjson and -tags goccyjson too.
+func TestMarshalSummaryBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary models.PaymentsSummaryResponse
+		want    string
+	}{
+		{
+			name:    "empty",
+			summary: models.PaymentsSummaryResponse{},
+			want:    `{"default":{"totalRequests":0,"totalAmount":0},"fallback":{"totalRequests":0,"totalAmount":0}}`,
+		},
+		{
+			name: "whole and fractional amounts",
+			summary: models.PaymentsSummaryResponse{
+				Default:  models.PaymentsSummary{TotalRequests: 3, TotalAmount: 12.0},
+				Fallback: models.PaymentsSummary{TotalRequests: 2, TotalAmount: 12.5},
+			},
+			want: `{"default":{"totalRequests":3,"totalAmount":12},"fallback":{"totalRequests":2,"totalAmount":12.5}}`,
+		},
+		{
+			name: "precision kept",
+			summary: models.PaymentsSummaryResponse{
+				Default:  models.PaymentsSummary{TotalRequests: 1, TotalAmount: 1234567.891},
+				Fallback: models.PaymentsSummary{TotalRequests: 1, TotalAmount: 0.0000001},
+			},
+			want: `{"default":{"totalRequests":1,"totalAmount":1234567.891},"fallback":{"totalRequests":1,"totalAmount":1e-7}}`,
+		},
+		{
+			name: "exponents",
+			summary: models.PaymentsSummaryResponse{
+				Default:  models.PaymentsSummary{TotalRequests: 1, TotalAmount: 1e21},
+				Fallback: models.PaymentsSummary{TotalRequests: 1, TotalAmount: -0.00000012},
+			},
+			want: `{"default":{"totalRequests":1,"totalAmount":1e+21},"fallback":{"totalRequests":1,"totalAmount":-1.2e-7}}`,
+		},
+		{
+			name: "dry run and latency",
+			summary: models.PaymentsSummaryResponse{
+				Default: models.PaymentsSummary{
+					TotalRequests: 1,
+					TotalAmount:   19.9,
+					Latency:       &models.LatencySummary{P50: 10, P99: 10.5},
+				},
+				DryRun: &models.PaymentsSummary{TotalRequests: 1, TotalAmount: 19.9},
+			},
+			want: `{"default":{"totalRequests":1,"totalAmount":19.9,"latency":{"p50":10,"p99":10.5}},"fallback":{"totalRequests":0,"totalAmount":0},"dryRun":{"totalRequests":1,"totalAmount":19.9}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s rendered\n%s\nwant\n%s", Library, got, tt.want)
+			}
+
+			// the handlers write through an encoder, which adds a newline
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf).Encode(tt.summary); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want+"\n" {
+				t.Errorf("%s encoded\n%s\nwant\n%s", Library, buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkProcessPaymentTask is the encoding every queued payment goes
+// through twice; compare runs with -tags stdjson and -tags goccyjson through
+// benchstat.
+func BenchmarkProcessPaymentTask(b *testing.B) {
+	task := tasks.ProcessPaymentTask{
+		ProcessPaymentPayload: tasks.ProcessPaymentPayload{
+			CorrelationId: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+			RequestedAt:   "2025-07-01T12:00:00.123456789Z",
+			Amount:        19.9,
+		},
+		OnDefault:       true,
+		Tries:           2,
+		ForcedProcessor: tasks.ProcessorFallback,
+	}
+	data, err := Marshal(task)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := Marshal(task); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			decoded := tasks.ProcessPaymentTask{}
+			if err := Unmarshal(data, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
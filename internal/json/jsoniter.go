@@ -0,0 +1,74 @@
+//go:build !stdjson && !goccyjson
+
+package json
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unsafe"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const Library = "jsoniter"
+
+// api is ConfigFastest without MarshalFloatWith6Digits, which would cut
+// amounts and latencies to six decimals and render 1e-7 as 0, unlike
+// encoding/json and what the processors and the summary checks expect.
+var api = jsoniter.Config{
+	EscapeHTML:                    false,
+	ObjectFieldMustBeSimpleString: true,
+}.Froze()
+
+// floats are written the way encoding/json writes them: jsoniter would render
+// 1e-7 as 1e-07. The registration is global to jsoniter, which only api uses.
+func init() {
+	jsoniter.RegisterTypeEncoderFunc("float64", func(ptr unsafe.Pointer, stream *jsoniter.Stream) {
+		f := *(*float64)(ptr)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			stream.Error = fmt.Errorf("json: unsupported value: %v", f)
+			return
+		}
+		stream.SetBuffer(appendFloat(stream.Buffer(), f))
+	}, func(ptr unsafe.Pointer) bool {
+		return *(*float64)(ptr) == 0
+	})
+}
+
+// appendFloat formats f like encoding/json: plain notation, switching to an
+// exponent with at least one digit below 1e-6 and from 1e21 on.
+func appendFloat(b []byte, f float64) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// e-07 to e-7
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+func Marshal(v any) ([]byte, error) {
+	return api.Marshal(v)
+}
+
+func Unmarshal(data []byte, v any) error {
+	return api.Unmarshal(data, v)
+}
+
+func NewEncoder(w io.Writer) Encoder {
+	return api.NewEncoder(w)
+}
+
+func NewDecoder(r io.Reader) Decoder {
+	return api.NewDecoder(r)
+}
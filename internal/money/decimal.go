@@ -0,0 +1,21 @@
+//go:build decimal
+
+package money
+
+import "github.com/shopspring/decimal"
+
+const Library = "shopspring/decimal"
+
+// Sum adds amounts up. The zero value is an empty sum.
+type Sum struct {
+	total decimal.Decimal
+}
+
+func (s *Sum) Add(amount float64) {
+	s.total = s.total.Add(decimal.NewFromFloat(amount))
+}
+
+// Round returns the sum rounded half away from zero to places decimals.
+func (s *Sum) Round(places int) float64 {
+	return s.total.Round(int32(places)).InexactFloat64()
+}
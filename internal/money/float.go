@@ -0,0 +1,22 @@
+//go:build !decimal
+
+package money
+
+import "math"
+
+const Library = "float64"
+
+// Sum adds amounts up. The zero value is an empty sum.
+type Sum struct {
+	total float64
+}
+
+func (s *Sum) Add(amount float64) {
+	s.total += amount
+}
+
+// Round returns the sum rounded half away from zero to places decimals.
+func (s *Sum) Round(places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(s.total*scale) / scale
+}
@@ -0,0 +1,4 @@
+// Package money accumulates payment amounts for the summaries. Amounts are
+// summed as float64 by default, or with shopspring/decimal using -tags decimal
+// for exact arithmetic, converting back to float64 only for the response.
+package money
@@ -0,0 +1,43 @@
+package money
+
+import "testing"
+
+func TestSumRound(t *testing.T) {
+	tests := []struct {
+		amounts []float64
+		places  int
+		want    float64
+	}{
+		{amounts: nil, places: 2, want: 0},
+		{amounts: []float64{0.1, 0.2}, places: 2, want: 0.3},
+		{amounts: []float64{19.9, 19.9, 19.9}, places: 2, want: 59.7},
+		{amounts: []float64{0.125}, places: 2, want: 0.13},
+		{amounts: []float64{1.005, 1.005}, places: 2, want: 2.01},
+	}
+	for _, tt := range tests {
+		s := Sum{}
+		for _, amount := range tt.amounts {
+			s.Add(amount)
+		}
+		if got := s.Round(tt.places); got != tt.want {
+			t.Errorf("%s sum of %v rounded to %d places = %v, want %v", Library, tt.amounts, tt.places, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkSum sums a summary's worth of payments; run it with and without
+// -tags decimal to compare both.
+func BenchmarkSum(b *testing.B) {
+	b.Run(Library, func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			s := Sum{}
+			for range 10_000 {
+				s.Add(19.9)
+			}
+			if got := s.Round(2); got != 199_000 {
+				b.Fatalf("sum = %v, want 199000", got)
+			}
+		}
+	})
+}